@@ -1,22 +1,35 @@
 package api
 
 import (
+	"candles-api/attest"
+	"candles-api/metrics"
 	"candles-api/store"
+	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
-const Port = 8889
+const (
+	Port            = 8889
+	ShutdownTimeout = time.Second * 10
+)
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
 type Api struct {
-	store *store.Store
+	store      *store.Store
+	signer     *attest.Signer
+	adminToken string
 }
 
 func NewApi(
@@ -27,6 +40,18 @@ func NewApi(
 	}
 }
 
+func (a *Api) WithSigner(signer *attest.Signer) *Api {
+	a.signer = signer
+	return a
+}
+
+// WithAdminToken enables the quarantine release and discard routes for
+// requests carrying the token as a bearer credential.
+func (a *Api) WithAdminToken(token string) *Api {
+	a.adminToken = token
+	return a
+}
+
 func (a *Api) getCandles(
 	c *gin.Context,
 	marketId string,
@@ -61,9 +86,184 @@ func (a *Api) getCandles(
 	}
 }
 
-func (a *Api) Start() {
+func (a *Api) market(c *gin.Context) *store.Config {
+	marketId := c.Param("marketId")
+	for _, config := range a.store.Config() {
+		if config.MarketId == marketId {
+			return config
+		}
+	}
+	c.JSON(http.StatusNotFound, &ErrorResponse{Error: "market not found"})
+	return nil
+}
+
+func (a *Api) getMarkets(c *gin.Context) {
+	c.JSON(http.StatusOK, a.store.GetMarketStatuses(a.store.Now()))
+}
+
+func (a *Api) getMarket(c *gin.Context) {
+	if config := a.market(c); config != nil {
+		c.JSON(http.StatusOK, a.store.GetMarketStatus(config, a.store.Now()))
+	}
+}
+
+func (a *Api) getTickers(c *gin.Context) {
+	c.JSON(http.StatusOK, a.store.GetTickers(a.store.Now()))
+}
+
+func (a *Api) getTicker(c *gin.Context) {
+	if config := a.market(c); config != nil {
+		c.JSON(http.StatusOK, a.store.GetTicker(config, a.store.Now()))
+	}
+}
+
+func (a *Api) referenceError(c *gin.Context, err error) {
+	if errors.Is(err, store.ErrNoPrice) {
+		c.JSON(http.StatusNotFound, &ErrorResponse{Error: err.Error()})
+	} else if errors.Is(err, store.ErrStalePrice) || errors.Is(err, store.ErrFutureTimestamp) {
+		c.JSON(http.StatusUnprocessableEntity, &ErrorResponse{Error: err.Error()})
+	} else {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: err.Error()})
+	}
+}
+
+func (a *Api) priceQuote(c *gin.Context) *store.PriceQuote {
+	config := a.market(c)
+	if config == nil {
+		return nil
+	}
+	timestamp, err1 := strconv.ParseUint(c.Param("timestamp"), 10, 0)
+	policy, err2 := store.ParsePricePolicy(c.Query("policy"))
+	maxStaleMinutes := 0
+	var err3 error
+	if value := c.Query("maxStaleMinutes"); len(value) > 0 {
+		maxStaleMinutes, err3 = strconv.Atoi(value)
+	}
+	if err1 != nil {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "timestamp format invalid"})
+	} else if err2 != nil {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: err2.Error()})
+	} else if err3 != nil || maxStaleMinutes < 0 {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "maxStaleMinutes format invalid"})
+	} else if quote, err := a.store.PriceAt(config.MarketId, timestamp, policy, maxStaleMinutes, a.store.Now()); err != nil {
+		a.referenceError(c, err)
+	} else {
+		return quote
+	}
+	return nil
+}
+
+func (a *Api) averageQuote(c *gin.Context) *store.AverageQuote {
+	config := a.market(c)
+	if config == nil {
+		return nil
+	}
+	fromTimestamp, err1 := strconv.ParseUint(c.Param("fromTimestamp"), 10, 0)
+	toTimestamp, err2 := strconv.ParseUint(c.Param("toTimestamp"), 10, 0)
+	if err1 != nil {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "fromTimestamp format invalid"})
+	} else if err2 != nil {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "toTimestamp format invalid"})
+	} else if quote, err := a.store.AveragePrice(config.MarketId, fromTimestamp, toTimestamp, a.store.Now()); err != nil {
+		a.referenceError(c, err)
+	} else {
+		return quote
+	}
+	return nil
+}
+
+func (a *Api) getPrice(c *gin.Context) {
+	if quote := a.priceQuote(c); quote != nil {
+		c.JSON(http.StatusOK, quote)
+	}
+}
+
+func (a *Api) getAveragePrice(c *gin.Context) {
+	if quote := a.averageQuote(c); quote != nil {
+		c.JSON(http.StatusOK, quote)
+	}
+}
+
+func (a *Api) getGaps(c *gin.Context) {
+	if config := a.market(c); config != nil {
+		c.JSON(http.StatusOK, a.store.GetGaps(config.MarketId))
+	}
+}
+
+func (a *Api) getFailover(c *gin.Context) {
+	if config := a.market(c); config != nil {
+		c.JSON(http.StatusOK, a.store.GetFailover(config, a.store.Now()))
+	}
+}
+
+func (a *Api) getQuarantined(c *gin.Context) {
+	if config := a.market(c); config != nil {
+		c.JSON(http.StatusOK, a.store.GetQuarantined(config.MarketId))
+	}
+}
+
+func (a *Api) adminAuthorized(c *gin.Context) bool {
+	if len(a.adminToken) == 0 {
+		c.JSON(http.StatusServiceUnavailable, &ErrorResponse{Error: "quarantine review is disabled, no admin token configured"})
+		return false
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.JSON(http.StatusUnauthorized, &ErrorResponse{Error: "admin token missing or invalid"})
+		return false
+	}
+	return true
+}
+
+func (a *Api) reviewQuarantined(c *gin.Context, review func(marketId string, closingTimestamp uint64) bool) {
+	if !a.adminAuthorized(c) {
+		return
+	}
+	config := a.market(c)
+	if config == nil {
+		return
+	}
+	closingTimestamp, err := strconv.ParseUint(c.Param("timestamp"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "timestamp format invalid"})
+	} else if !review(config.MarketId, closingTimestamp) {
+		c.JSON(http.StatusNotFound, &ErrorResponse{Error: "quarantined candle not found"})
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func observe(c *gin.Context) {
+	started := time.Now()
+	c.Next()
+	route := c.FullPath()
+	if len(route) == 0 {
+		route = "unmatched"
+	}
+	metrics.HttpLatency.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(metrics.Since(started))
+}
+
+func (a *Api) getHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (a *Api) getReadiness(c *gin.Context) {
+	report := a.store.Readiness(a.store.Now())
+	if report.Ready {
+		c.JSON(http.StatusOK, report)
+	} else {
+		c.JSON(http.StatusServiceUnavailable, report)
+	}
+}
+
+func (a *Api) Router() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
+	r.Use(observe)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/healthz", a.getHealth)
+	r.GET("/readyz", a.getReadiness)
 	r.GET("/data/:marketId/:interval/:fromTimestamp/:toTimestamp", func(c *gin.Context) {
 		marketId := c.Param("marketId")
 		intervalStr := c.Param("interval")
@@ -78,9 +278,60 @@ func (a *Api) Start() {
 		a.getCandles(c, marketId, intervalStr, fromTimestampStr, "")
 
 	})
-	log.Infof("listening on 0.0.0.0:%d", Port)
-	err := r.Run(fmt.Sprintf(":%d", Port))
+	r.GET("/markets", a.getMarkets)
+	r.GET("/markets/:marketId", a.getMarket)
+	r.GET("/ticker", a.getTickers)
+	r.GET("/ticker/:marketId", a.getTicker)
+	r.GET("/price/:marketId/:timestamp", a.getPrice)
+	r.GET("/twap/:marketId/:fromTimestamp/:toTimestamp", a.getAveragePrice)
+	r.GET("/attest/candle/:marketId/:interval/:closingTimestamp", a.attestCandle)
+	r.GET("/attest/price/:marketId/:timestamp", a.attestPrice)
+	r.GET("/attest/twap/:marketId/:fromTimestamp/:toTimestamp", a.attestAveragePrice)
+	r.GET("/gaps/:marketId", a.getGaps)
+	r.GET("/failover/:marketId", a.getFailover)
+	r.GET("/quarantine/:marketId", a.getQuarantined)
+	r.POST("/quarantine/:marketId/:timestamp/release", func(c *gin.Context) {
+		a.reviewQuarantined(c, a.store.ReleaseQuarantined)
+	})
+	r.DELETE("/quarantine/:marketId/:timestamp", func(c *gin.Context) {
+		a.reviewQuarantined(c, a.store.DiscardQuarantined)
+	})
+	r.GET("/stream", a.streamEvents)
+	r.GET("/ws", a.streamWebSocket)
+	return r
+}
+
+// Serve listens until ctx is done, then stops accepting connections and
+// waits up to ShutdownTimeout for in-flight requests to finish.
+func (a *Api) Serve(ctx context.Context) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", Port))
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	log.Infof("listening on 0.0.0.0:%d", Port)
+	return a.serve(ctx, listener)
+}
+
+// serve derives every request context from ctx, so long-lived SSE and
+// WebSocket sessions end as soon as shutdown starts instead of holding it
+// for the full ShutdownTimeout.
+func (a *Api) serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler: a.Router(),
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+	stopped := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		stopped <- server.Shutdown(shutdownCtx)
+	}()
+	err := server.Serve(listener)
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	return <-stopped
 }
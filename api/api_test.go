@@ -0,0 +1,158 @@
+package api
+
+import (
+	"candles-api/attest"
+	"candles-api/clock"
+	"candles-api/data"
+	"candles-api/provider"
+	"candles-api/store"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestApi_HealthAndReadiness(t *testing.T) {
+	btc := &store.Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT", Schedule: "24/7"}
+	s := store.NewStore([]*store.Interval{{Seconds: 60, Retention: time.Hour}}, []*store.Config{btc}, provider.NewRegistry(), nil, nil)
+	router := NewApi(s).Router()
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected healthz to succeed, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	report := &store.ReadinessReport{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), report); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusServiceUnavailable || report.Ready || len(report.Empty) != 1 || report.Empty[0].MarketId != "btc" {
+		t.Fatalf("expected an empty market to fail readiness, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	ts := uint64(time.Now().Truncate(time.Minute).UnixMilli())
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, ts, ts-60000, 1, 1, 1, 1, 0, 0))
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected readiness to wait for the initial sync, got %d", recorder.Code)
+	}
+}
+
+func TestApi_AttestCandle(t *testing.T) {
+	btc := &store.Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT"}
+	s := store.NewStore([]*store.Interval{{Seconds: 60, Retention: time.Hour}}, []*store.Config{btc}, provider.NewRegistry(), nil, nil)
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 1, 2, 2, 1, 0, 0))
+	a := NewApi(s)
+
+	recorder := httptest.NewRecorder()
+	a.Router().ServeHTTP(recorder, httptest.NewRequest("GET", "/attest/candle/btc/60/120000", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected attestations to be disabled without a key, got %d", recorder.Code)
+	}
+
+	router := a.WithSigner(attest.NewSigner(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))).Router()
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/attest/candle/btc/60/120000", nil))
+	attestation := &attest.Attestation{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), attestation); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || attest.Verify(attestation) != nil {
+		t.Fatalf("expected a verifiable attestation, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/attest/candle/btc/60/180000", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected a missing candle to be reported, got %d", recorder.Code)
+	}
+
+	forming := uint64(time.Now().Truncate(time.Minute).Add(time.Minute).UnixMilli())
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, forming, forming-60000, 1, 2, 2, 1, 0, 0))
+	future := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+	paths := []string{"/attest/candle/btc/60/" + strconv.FormatUint(forming, 10), "/attest/price/btc/" + future, "/attest/twap/btc/60000/" + future}
+	for _, path := range paths {
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected %s to be refused, got %d %s", path, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestApi_QuarantineReviewRequiresAdminToken(t *testing.T) {
+	btc := &store.Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT"}
+	s := store.NewStore([]*store.Interval{{Seconds: 60, Retention: time.Hour}}, []*store.Config{btc}, provider.NewRegistry(), nil, nil)
+	a := NewApi(s)
+
+	recorder := httptest.NewRecorder()
+	a.Router().ServeHTTP(recorder, httptest.NewRequest("POST", "/quarantine/btc/120000/release", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected quarantine review to be disabled without a token, got %d", recorder.Code)
+	}
+
+	router := a.WithAdminToken("secret").Router()
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		code          int
+	}{
+		{"list is public", "GET", "/quarantine/btc", "", http.StatusOK},
+		{"release without token", "POST", "/quarantine/btc/120000/release", "", http.StatusUnauthorized},
+		{"release with wrong token", "POST", "/quarantine/btc/120000/release", "Bearer wrong", http.StatusUnauthorized},
+		{"discard without token", "DELETE", "/quarantine/btc/120000", "", http.StatusUnauthorized},
+		{"release with token", "POST", "/quarantine/btc/120000/release", "Bearer secret", http.StatusNotFound},
+		{"discard with token", "DELETE", "/quarantine/btc/120000", "Bearer secret", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, test.path, nil)
+			if len(test.authorization) > 0 {
+				request.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != test.code {
+				t.Fatalf("expected %d, got %d %s", test.code, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestApi_AttestFollowsStoreClock(t *testing.T) {
+	btc := &store.Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT"}
+	manual := clock.NewManual(time.UnixMilli(150000))
+	s := store.NewStore([]*store.Interval{{Seconds: 60, Retention: time.Hour}}, []*store.Config{btc}, provider.NewRegistry(), nil, nil).WithClock(manual)
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 1, 2, 2, 1, 0, 0))
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 180000, 120000, 2, 3, 3, 2, 0, 0))
+	router := NewApi(s).WithSigner(attest.NewSigner(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))).Router()
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/attest/candle/btc/60/180000", nil))
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected the candle forming on the store clock to be refused, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/attest/candle/btc/60/120000", nil))
+	attestation := &attest.Attestation{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), attestation); err != nil {
+		t.Fatal(err)
+	}
+	message := &attest.CandleMessage{}
+	if err := json.Unmarshal([]byte(attestation.Message), message); err != nil {
+		t.Fatal(err)
+	}
+	if message.SignedAt != 150000 {
+		t.Fatalf("expected the attestation to be signed at the store time, got %d", message.SignedAt)
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"candles-api/attest"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func respondSigned(c *gin.Context, attestation *attest.Attestation, err error) {
+	if errors.Is(err, attest.ErrFutureQuote) {
+		c.JSON(http.StatusUnprocessableEntity, &ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, &ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, attestation)
+}
+
+func (a *Api) signerConfigured(c *gin.Context) bool {
+	if a.signer == nil {
+		c.JSON(http.StatusServiceUnavailable, &ErrorResponse{Error: "attestations are disabled, no signing key configured"})
+		return false
+	}
+	return true
+}
+
+func (a *Api) attestCandle(c *gin.Context) {
+	if !a.signerConfigured(c) {
+		return
+	}
+	config := a.market(c)
+	if config == nil {
+		return
+	}
+	interval, err1 := strconv.ParseUint(c.Param("interval"), 10, 0)
+	closingTimestamp, err2 := strconv.ParseUint(c.Param("closingTimestamp"), 10, 0)
+	if err1 != nil {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "interval format invalid"})
+		return
+	}
+	if err2 != nil {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "closingTimestamp format invalid"})
+		return
+	}
+	candles := a.store.GetCandles(config.MarketId, interval, closingTimestamp, closingTimestamp)
+	if len(candles) == 0 {
+		c.JSON(http.StatusNotFound, &ErrorResponse{Error: "candle not found"})
+		return
+	}
+	attestation, err := a.signer.SignCandle(candles[0], a.store.Now())
+	respondSigned(c, attestation, err)
+}
+
+func (a *Api) attestPrice(c *gin.Context) {
+	if !a.signerConfigured(c) {
+		return
+	}
+	if quote := a.priceQuote(c); quote != nil {
+		attestation, err := a.signer.SignPrice(quote, a.store.Now())
+		respondSigned(c, attestation, err)
+	}
+}
+
+func (a *Api) attestAveragePrice(c *gin.Context) {
+	if !a.signerConfigured(c) {
+		return
+	}
+	if quote := a.averageQuote(c); quote != nil {
+		attestation, err := a.signer.SignAverage(quote, a.store.Now())
+		respondSigned(c, attestation, err)
+	}
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"candles-api/data"
+	"candles-api/store"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	SnapshotMessage = "snapshot"
+	CandleMessage   = "candle"
+	ErrorMessage    = "error"
+)
+
+type StreamRequest struct {
+	Op            string `json:"op"`
+	MarketId      string `json:"marketId"`
+	Interval      uint64 `json:"interval"`
+	FromTimestamp uint64 `json:"fromTimestamp"`
+}
+
+type StreamMessage struct {
+	Type     string         `json:"type"`
+	MarketId string         `json:"marketId,omitempty"`
+	Interval uint64         `json:"interval,omitempty"`
+	Candles  []*data.Candle `json:"candles,omitempty"`
+	Candle   *data.Candle   `json:"candle,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+type streamSession struct {
+	store         *store.Store
+	out           chan *StreamMessage
+	subscriptions map[string]*store.Subscription
+	lock          sync.Mutex
+	closed        bool
+	done          chan struct{}
+}
+
+func newStreamSession(s *store.Store) *streamSession {
+	return &streamSession{
+		store:         s,
+		out:           make(chan *StreamMessage, store.SubscriptionBuffer),
+		subscriptions: map[string]*store.Subscription{},
+		done:          make(chan struct{}),
+	}
+}
+
+// subscribe registers for updates before taking the snapshot, so every
+// change is either part of the snapshot or delivered afterwards as a delta.
+// Consumers should upsert by closingTimestamp as a candle may appear in both.
+func (ss *streamSession) subscribe(marketId string, interval uint64, fromTimestamp uint64) {
+	key := fmt.Sprintf("%s:%d", marketId, interval)
+	ss.lock.Lock()
+	if _, ok := ss.subscriptions[key]; ok || ss.closed {
+		ss.lock.Unlock()
+		return
+	}
+	sub := ss.store.Subscribe(marketId, interval)
+	ss.subscriptions[key] = sub
+	ss.lock.Unlock()
+	ss.send(&StreamMessage{
+		Type:     SnapshotMessage,
+		MarketId: marketId,
+		Interval: interval,
+		Candles:  ss.store.GetCandles(marketId, interval, fromTimestamp, 0),
+	})
+	go func() {
+		for candle := range sub.C {
+			ss.send(&StreamMessage{Type: CandleMessage, MarketId: marketId, Interval: interval, Candle: candle})
+		}
+		ss.lock.Lock()
+		current := ss.subscriptions[key]
+		if current == sub {
+			delete(ss.subscriptions, key)
+		}
+		ss.lock.Unlock()
+		if current == sub {
+			ss.send(&StreamMessage{Type: ErrorMessage, MarketId: marketId, Interval: interval, Error: "subscription dropped, resubscribe"})
+		}
+	}()
+}
+
+func (ss *streamSession) unsubscribe(marketId string, interval uint64) {
+	key := fmt.Sprintf("%s:%d", marketId, interval)
+	ss.lock.Lock()
+	sub := ss.subscriptions[key]
+	delete(ss.subscriptions, key)
+	ss.lock.Unlock()
+	if sub != nil {
+		sub.Close()
+	}
+}
+
+func (ss *streamSession) send(msg *StreamMessage) {
+	select {
+	case ss.out <- msg:
+	case <-ss.done:
+	}
+}
+
+func (ss *streamSession) close() {
+	close(ss.done)
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.closed = true
+	for key, sub := range ss.subscriptions {
+		delete(ss.subscriptions, key)
+		sub.Close()
+	}
+}
+
+func (a *Api) streamEvents(c *gin.Context) {
+	pairs := c.QueryArray("market")
+	if len(pairs) == 0 {
+		c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "market required"})
+		return
+	}
+	fromTimestamp := uint64(1)
+	if fromTimestampStr := c.Query("fromTimestamp"); len(fromTimestampStr) > 0 {
+		var err error
+		fromTimestamp, err = strconv.ParseUint(fromTimestampStr, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "fromTimestamp format invalid"})
+			return
+		}
+	}
+	type pair struct {
+		marketId string
+		interval uint64
+	}
+	subscriptions := make([]pair, 0, len(pairs))
+	for _, p := range pairs {
+		marketId, intervalStr, ok := strings.Cut(p, ":")
+		interval, err := strconv.ParseUint(intervalStr, 10, 0)
+		if !ok || len(marketId) == 0 || err != nil {
+			c.JSON(http.StatusBadRequest, &ErrorResponse{Error: "market format invalid, expected <marketId>:<interval>"})
+			return
+		}
+		subscriptions = append(subscriptions, pair{marketId: marketId, interval: interval})
+	}
+	session := newStreamSession(a.store)
+	defer session.close()
+	go func() {
+		for _, p := range subscriptions {
+			session.subscribe(p.marketId, p.interval, fromTimestamp)
+		}
+	}()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case msg := <-session.out:
+			c.SSEvent(msg.Type, msg)
+			return true
+		}
+	})
+}
+
+func (a *Api) streamWebSocket(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Errorf("cannot upgrade websocket %v", err)
+		return
+	}
+	defer conn.Close()
+	session := newStreamSession(a.store)
+	defer session.close()
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			req := &StreamRequest{}
+			err := conn.ReadJSON(req)
+			if err != nil {
+				return
+			}
+			if len(req.MarketId) == 0 || req.Interval == 0 {
+				session.send(&StreamMessage{Type: ErrorMessage, Error: "marketId and interval required"})
+				continue
+			}
+			switch req.Op {
+			case "subscribe":
+				if req.FromTimestamp == 0 {
+					req.FromTimestamp = 1
+				}
+				session.subscribe(req.MarketId, req.Interval, req.FromTimestamp)
+			case "unsubscribe":
+				session.unsubscribe(req.MarketId, req.Interval)
+			default:
+				session.send(&StreamMessage{Type: ErrorMessage, Error: fmt.Sprintf("unknown op %q", req.Op)})
+			}
+		}
+	}()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-c.Request.Context().Done():
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+			return
+		case msg := <-session.out:
+			err = conn.WriteJSON(msg)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bufio"
+	"candles-api/data"
+	"candles-api/provider"
+	"candles-api/store"
+	"context"
+	"github.com/gorilla/websocket"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApi_StreamWebSocket(t *testing.T) {
+	s := store.NewStore([]*store.Interval{{Seconds: 60, Retention: time.Hour}}, []*store.Config{}, provider.NewRegistry(), nil, nil)
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 1, 1, 1, 1, 0, 0))
+	server := httptest.NewServer(NewApi(s).Router())
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	if err = conn.WriteJSON(&StreamRequest{Op: "subscribe", MarketId: "btc", Interval: 60}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := &StreamMessage{}
+	if err = conn.ReadJSON(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Type != SnapshotMessage || len(snapshot.Candles) != 1 || snapshot.Candles[0].ClosingTimestamp != 60000 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 1, 2, 2, 1, 0, 0))
+	update := &StreamMessage{}
+	if err = conn.ReadJSON(update); err != nil {
+		t.Fatal(err)
+	}
+	if update.Type != CandleMessage || update.Candle == nil || update.Candle.ClosingTimestamp != 120000 {
+		t.Fatalf("unexpected update %+v", update)
+	}
+}
+
+func TestApi_ServeEndsStreamsOnShutdown(t *testing.T) {
+	s := store.NewStore([]*store.Interval{{Seconds: 60, Retention: time.Hour}}, []*store.Config{}, provider.NewRegistry(), nil, nil)
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 1, 1, 1, 1, 0, 0))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		served <- NewApi(s).serve(ctx, listener)
+	}()
+	address := listener.Addr().String()
+
+	response, err := http.Get("http://" + address + "/stream?market=btc:60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if _, err = bufio.NewReader(response.Body).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+address+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = conn.WriteJSON(&StreamRequest{Op: "subscribe", MarketId: "btc", Interval: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.ReadJSON(&StreamMessage{}); err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	cancel()
+	select {
+	case err = <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(ShutdownTimeout / 2):
+		t.Fatal("expected shutdown not to wait for open streams")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second*2 {
+		t.Fatalf("shutdown took %s", elapsed)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected the websocket to be closed as going away, got %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package attest
+
+import (
+	"candles-api/data"
+	"candles-api/store"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Algorithm = "ed25519"
+	Version   = 1
+)
+
+// Attestation carries the exact bytes that were signed as Message, so a
+// verifier checks the signature against Message before parsing it.
+type Attestation struct {
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+	PublicKey string `json:"publicKey"`
+	Algorithm string `json:"algorithm"`
+}
+
+// ErrFutureQuote is returned when asked to sign a price, window or candle
+// that ends after the signing time, its values can still change.
+var ErrFutureQuote = errors.New("quote is in the future")
+
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{key: key}
+}
+
+// LoadKey reads an ed25519 private key from a PEM encoded PKCS #8 file, or
+// from a file holding the hex encoded 32 byte seed or 64 byte private key.
+func LoadKey(path string) (ed25519.PrivateKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read signing key %v", err)
+	}
+	if block, _ := pem.Decode(raw); block != nil {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse signing key %v", err)
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("signing key is a %T, expected an ed25519 key", parsed)
+		}
+		return key, nil
+	}
+	decoded, err := hex.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse signing key %v", err)
+	}
+	switch len(decoded) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(decoded), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(decoded), nil
+	default:
+		return nil, fmt.Errorf("signing key has %d bytes, expected %d or %d", len(decoded), ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+}
+
+func (s *Signer) PublicKey() string {
+	return hex.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+func (s *Signer) sign(message any) (*Attestation, error) {
+	raw, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("cannot serialize attestation %v", err)
+	}
+	return &Attestation{
+		Message:   string(raw),
+		Signature: hex.EncodeToString(ed25519.Sign(s.key, raw)),
+		PublicKey: s.PublicKey(),
+		Algorithm: Algorithm,
+	}, nil
+}
+
+func Verify(attestation *Attestation) error {
+	if attestation.Algorithm != Algorithm {
+		return fmt.Errorf("unsupported algorithm %q", attestation.Algorithm)
+	}
+	publicKey, err := hex.DecodeString(attestation.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key")
+	}
+	signature, err := hex.DecodeString(attestation.Signature)
+	if err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	if !ed25519.Verify(publicKey, []byte(attestation.Message), signature) {
+		return errors.New("signature does not match message")
+	}
+	return nil
+}
+
+// Messages are serialised with a fixed field order and prices as decimal
+// strings, so the signed bytes do not depend on a JSON float formatter.
+
+type CandleMessage struct {
+	Type             string `json:"type"`
+	Version          int    `json:"version"`
+	MarketId         string `json:"marketId"`
+	Interval         uint64 `json:"interval"`
+	OpeningTimestamp uint64 `json:"openingTimestamp"`
+	ClosingTimestamp uint64 `json:"closingTimestamp"`
+	Open             string `json:"open"`
+	High             string `json:"high"`
+	Low              string `json:"low"`
+	Close            string `json:"close"`
+	Volume           string `json:"volume"`
+	Turnover         string `json:"turnover"`
+	SignedAt         int64  `json:"signedAt"`
+}
+
+type PriceMessage struct {
+	Type      string   `json:"type"`
+	Version   int      `json:"version"`
+	MarketId  string   `json:"marketId"`
+	Timestamp uint64   `json:"timestamp"`
+	Policy    string   `json:"policy"`
+	Price     string   `json:"price"`
+	Candles   []uint64 `json:"candles"`
+	SignedAt  int64    `json:"signedAt"`
+}
+
+type AverageMessage struct {
+	Type     string   `json:"type"`
+	Version  int      `json:"version"`
+	MarketId string   `json:"marketId"`
+	From     uint64   `json:"from"`
+	To       uint64   `json:"to"`
+	Twap     string   `json:"twap"`
+	Vwap     string   `json:"vwap,omitempty"`
+	Candles  []uint64 `json:"candles"`
+	SignedAt int64    `json:"signedAt"`
+}
+
+func decimal(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func closingTimestamps(candles []*data.Candle) []uint64 {
+	timestamps := make([]uint64, 0, len(candles))
+	for _, candle := range candles {
+		timestamps = append(timestamps, candle.ClosingTimestamp)
+	}
+	return timestamps
+}
+
+func (s *Signer) SignCandle(candle *data.Candle, now time.Time) (*Attestation, error) {
+	if candle.ClosingTimestamp > uint64(now.UnixMilli()) {
+		return nil, fmt.Errorf("%w, candle closing at %d is still forming at %d", ErrFutureQuote, candle.ClosingTimestamp, now.UnixMilli())
+	}
+	return s.sign(&CandleMessage{
+		Type:             "candle",
+		Version:          Version,
+		MarketId:         candle.MarketId,
+		Interval:         candle.Interval,
+		OpeningTimestamp: candle.OpeningTimestamp,
+		ClosingTimestamp: candle.ClosingTimestamp,
+		Open:             decimal(candle.Open),
+		High:             decimal(candle.High),
+		Low:              decimal(candle.Low),
+		Close:            decimal(candle.Close),
+		Volume:           decimal(candle.Volume),
+		Turnover:         decimal(candle.Turnover),
+		SignedAt:         now.UnixMilli(),
+	})
+}
+
+func (s *Signer) SignPrice(quote *store.PriceQuote, now time.Time) (*Attestation, error) {
+	if quote.Timestamp > uint64(now.UnixMilli()) {
+		return nil, fmt.Errorf("%w, %d is after %d", ErrFutureQuote, quote.Timestamp, now.UnixMilli())
+	}
+	return s.sign(&PriceMessage{
+		Type:      "price",
+		Version:   Version,
+		MarketId:  quote.MarketId,
+		Timestamp: quote.Timestamp,
+		Policy:    string(quote.Policy),
+		Price:     decimal(quote.Price),
+		Candles:   closingTimestamps(quote.Candles),
+		SignedAt:  now.UnixMilli(),
+	})
+}
+
+func (s *Signer) SignAverage(quote *store.AverageQuote, now time.Time) (*Attestation, error) {
+	if quote.To > uint64(now.UnixMilli()) {
+		return nil, fmt.Errorf("%w, window end %d is after %d", ErrFutureQuote, quote.To, now.UnixMilli())
+	}
+	message := &AverageMessage{
+		Type:     "twap",
+		Version:  Version,
+		MarketId: quote.MarketId,
+		From:     quote.From,
+		To:       quote.To,
+		Twap:     decimal(quote.Twap),
+		Candles:  closingTimestamps(quote.Candles),
+		SignedAt: now.UnixMilli(),
+	}
+	if quote.Vwap != nil {
+		message.Vwap = decimal(*quote.Vwap)
+	}
+	return s.sign(message)
+}
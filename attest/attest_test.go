@@ -0,0 +1,123 @@
+package attest
+
+import (
+	"candles-api/data"
+	"candles-api/store"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var seed = make([]byte, ed25519.SeedSize)
+
+func TestLoadKey(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(seed)
+	dir := t.TempDir()
+
+	hexPath := filepath.Join(dir, "key.hex")
+	if err := os.WriteFile(hexPath, []byte(hex.EncodeToString(seed)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemPath := filepath.Join(dir, "key.pem")
+	if err = os.WriteFile(pemPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{hexPath, pemPath} {
+		loaded, err := LoadKey(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !loaded.Equal(key) {
+			t.Fatalf("unexpected key loaded from %s", path)
+		}
+	}
+
+	badPath := filepath.Join(dir, "key.bad")
+	if err = os.WriteFile(badPath, []byte("abcd"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadKey(badPath); err == nil {
+		t.Fatal("expected a short key to be rejected")
+	}
+}
+
+func TestSigner_SignCandle(t *testing.T) {
+	signer := NewSigner(ed25519.NewKeyFromSeed(seed))
+	candle := data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 1.5, 2, 2.25, 1, 10, 0.1)
+	attestation, err := signer.SignCandle(candle, time.UnixMilli(180000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"candle","version":1,"marketId":"btc","interval":60,"openingTimestamp":60000,"closingTimestamp":120000,` +
+		`"open":"1.5","high":"2.25","low":"1","close":"2","volume":"10","turnover":"0.1","signedAt":180000}`
+	if attestation.Message != expected {
+		t.Fatalf("unexpected message %s", attestation.Message)
+	}
+	if attestation.PublicKey != signer.PublicKey() || attestation.Algorithm != Algorithm {
+		t.Fatalf("unexpected attestation %+v", attestation)
+	}
+	if err = Verify(attestation); err != nil {
+		t.Fatal(err)
+	}
+	attestation.Message = `{"type":"candle","close":"3"}`
+	if err = Verify(attestation); err == nil {
+		t.Fatal("expected an altered message to fail verification")
+	}
+}
+
+func TestSigner_SignAverage(t *testing.T) {
+	signer := NewSigner(ed25519.NewKeyFromSeed(seed))
+	quote := &store.AverageQuote{
+		MarketId: "btc",
+		From:     0,
+		To:       120000,
+		Twap:     15,
+		Candles:  []*data.Candle{data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 10, 10, 10, 10, 0, 0)},
+	}
+	attestation, err := signer.SignAverage(quote, time.UnixMilli(180000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := &AverageMessage{}
+	if err = json.Unmarshal([]byte(attestation.Message), message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Twap != "15" || len(message.Vwap) != 0 || len(message.Candles) != 1 || message.Candles[0] != 60000 {
+		t.Fatalf("unexpected message %s", attestation.Message)
+	}
+	if err = Verify(attestation); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSigner_RefusesFutureQuotes(t *testing.T) {
+	signer := NewSigner(ed25519.NewKeyFromSeed(seed))
+	now := time.UnixMilli(180000)
+	price := &store.PriceQuote{MarketId: "btc", Timestamp: 180001, Policy: store.PreviousClose, Price: 10}
+	if _, err := signer.SignPrice(price, now); !errors.Is(err, ErrFutureQuote) {
+		t.Fatalf("expected a future price to be refused, got %v", err)
+	}
+	price.Timestamp = 180000
+	if _, err := signer.SignPrice(price, now); err != nil {
+		t.Fatalf("expected a price at now to be signed, got %v", err)
+	}
+	forming := data.NewCandle("BTCUSDT", "btc", 300, 300000, 0, 1, 1, 1, 1, 0, 0)
+	if _, err := signer.SignCandle(forming, now); !errors.Is(err, ErrFutureQuote) {
+		t.Fatalf("expected a forming candle to be refused, got %v", err)
+	}
+	average := &store.AverageQuote{MarketId: "btc", From: 120000, To: 240000, Twap: 10}
+	if _, err := signer.SignAverage(average, now); !errors.Is(err, ErrFutureQuote) {
+		t.Fatalf("expected a window ending in the future to be refused, got %v", err)
+	}
+}
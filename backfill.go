@@ -0,0 +1,94 @@
+package main
+
+import (
+	"candles-api/config"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// backfill writes into the journal of a data dir, which the running service
+// holds locked. Stop the service first and point --data-dir at its data dir,
+// /var/lib/candles-api when deployed.
+func backfill(args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: candles-api backfill --data-dir <dir> --market <id> --from <date> [--to <date>]\n\n")
+		fmt.Fprintf(flags.Output(), "Stop the running service first, it holds the journal in --data-dir locked.\n")
+		fmt.Fprintf(flags.Output(), "The deployed service keeps its journal in /var/lib/candles-api.\n\n")
+		flags.PrintDefaults()
+	}
+	configPath := configFlag(flags)
+	dataDir := dataDirFlag(flags)
+	backendName := backendFlag(flags)
+	marketId := flags.String("market", "", "market id to backfill")
+	fromStr := flags.String("from", "", "start date, YYYY-MM-DD or RFC 3339")
+	toStr := flags.String("to", "", "end date, YYYY-MM-DD or RFC 3339, defaults to now")
+	recording := trafficFlags(flags)
+	_ = flags.Parse(args)
+	recording.start(flags, *dataDir)
+	if len(*marketId) == 0 {
+		log.Fatal("--market required")
+	}
+	explicit := len(os.Getenv("CANDLES_DATA_DIR")) > 0
+	flags.Visit(func(f *flag.Flag) {
+		explicit = explicit || f.Name == "data-dir"
+	})
+	if !explicit {
+		log.Fatal("--data-dir required, backfill into the data dir of the service, /var/lib/candles-api when deployed")
+	}
+	from, err := parseDate(*fromStr)
+	if err != nil {
+		log.Fatalf("--from %v", err)
+	}
+	to := recording.clock.Now()
+	if len(*toStr) > 0 {
+		to, err = parseDate(*toStr)
+		if err != nil {
+			log.Fatalf("--to %v", err)
+		}
+	}
+	if !from.Before(to) {
+		log.Fatal("--from must be before --to")
+	}
+	providers := newProviders(recording)
+	cfg, err := config.Load(config.Path(*configPath), providers.Names())
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	appStore, candleJournal := newStore(cfg, providers, *dataDir, *backendName)
+	appStore.WithClock(recording.clock)
+	saved, err := appStore.Backfill(ctx, *marketId, from, to)
+	if closeErr := appStore.Close(); closeErr != nil {
+		log.Errorf("cannot flush journal %v", closeErr)
+	}
+	if closeErr := candleJournal.Close(); closeErr != nil {
+		log.Errorf("cannot close journal %v", closeErr)
+	}
+	recording.close()
+	if err != nil {
+		log.Fatalf("backfill stopped after %d candles: %v", saved, err)
+	}
+	log.Infof("backfilled %d candles for %s", saved, *marketId)
+}
+
+func parseDate(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, fmt.Errorf("date required")
+	}
+	if date, err := time.ParseInLocation(time.DateOnly, value, time.UTC); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date format invalid %q", value)
+	}
+	return date, nil
+}
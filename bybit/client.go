@@ -2,34 +2,99 @@ package bybit
 
 import (
 	"candles-api/data"
+	"candles-api/provider"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/charmbracelet/log"
 	"github.com/go-resty/resty/v2"
+	"net/http"
 	"strconv"
+	"time"
 )
 
+const Name = "bybit"
+
+var intervals = map[uint64]string{
+	60:    "1",
+	300:   "5",
+	900:   "15",
+	1800:  "30",
+	3600:  "60",
+	14400: "240",
+	86400: "D",
+}
+
 type Client struct {
-	host string
+	baseUrl   string
+	streamUrl string
+	client    *resty.Client
 }
 
 func NewClient(host string) *Client {
-	return &Client{host: host}
+	return &Client{baseUrl: "https://" + host, streamUrl: DefaultStreamUrl, client: resty.New()}
+}
+
+func (c *Client) WithBaseUrl(baseUrl string) *Client {
+	c.baseUrl = baseUrl
+	return c
+}
+
+func (c *Client) WithHttpClient(httpClient *http.Client) *Client {
+	c.client = resty.NewWithClient(httpClient)
+	return c
+}
+
+func (c *Client) WithStreamUrl(streamUrl string) *Client {
+	c.streamUrl = streamUrl
+	return c
+}
+
+func (c *Client) Name() string {
+	return Name
+}
+
+func (c *Client) Intervals() []uint64 {
+	return []uint64{60, 300, 900, 1800, 3600, 14400, 86400}
+}
+
+func (c *Client) PollInterval() time.Duration {
+	return time.Second
 }
 
-func (c *Client) GetLatestCandles(symbol string) []*data.Candle {
-	client := resty.New()
-	url := fmt.Sprintf("https://%s/v5/market/kline?symbol=%s&interval=1&category=linear&limit=1000", c.host, symbol)
-	resp, err := client.R().Get(url)
+func (c *Client) GetLatestCandles(ctx context.Context, symbol string, _ string) ([]*data.Candle, error) {
+	url := fmt.Sprintf("%s/v5/market/kline?symbol=%s&interval=1&category=linear&limit=1000", c.baseUrl, symbol)
+	return c.getCandles(ctx, url, symbol, 60)
+}
+
+func (c *Client) GetCandles(ctx context.Context, symbol string, _ string, interval uint64, from time.Time, to time.Time) ([]*data.Candle, error) {
+	intervalStr, ok := intervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("bybit does not support interval %d", interval)
+	}
+	return provider.Page(ctx, from, to, interval, 1000, time.Millisecond*100, func(from time.Time, to time.Time) ([]*data.Candle, error) {
+		url := fmt.Sprintf(
+			"%s/v5/market/kline?symbol=%s&interval=%s&category=linear&limit=1000&start=%d&end=%d",
+			c.baseUrl, symbol, intervalStr, from.UnixMilli(), to.UnixMilli(),
+		)
+		return c.getCandles(ctx, url, symbol, interval)
+	})
+}
+
+func (c *Client) getCandles(ctx context.Context, url string, symbol string, interval uint64) ([]*data.Candle, error) {
+	resp, err := c.client.R().SetContext(ctx).Get(url)
 	candles := make([]*data.Candle, 0)
 	if err != nil {
-		log.Errorf("cannot get candles from bybit %v", err)
-		return candles
+		return candles, fmt.Errorf("cannot get candles from bybit %v", err)
 	}
 	if resp.StatusCode() != 200 {
-		log.Errorf("cannot get candles from bybit %s", string(resp.Body()))
-		return candles
+		return candles, fmt.Errorf("cannot get candles from bybit %s %s", resp.Status(), string(resp.Body()))
 	}
+	return parseKlines(resp.Body(), symbol, interval)
+}
+
+func parseKlines(body []byte, symbol string, interval uint64) ([]*data.Candle, error) {
+	candles := make([]*data.Candle, 0)
 	res := struct {
 		RetMsg string `json:"retMsg"`
 		Result struct {
@@ -37,36 +102,53 @@ func (c *Client) GetLatestCandles(symbol string) []*data.Candle {
 			List   [][]string `json:"list"`
 		}
 	}{}
-	err = json.Unmarshal(resp.Body(), &res)
+	err := json.Unmarshal(body, &res)
 	if err != nil {
-		log.Errorf("cannot get candles from bybit %v", err)
-		return candles
+		return candles, fmt.Errorf("cannot get candles from bybit %v", err)
 	}
 	if res.RetMsg != "OK" {
-		log.Errorf("cannot get candles from bybit %s", string(resp.Body()))
-		return candles
+		return candles, fmt.Errorf("cannot get candles from bybit %s", string(body))
 	}
 	for _, item := range res.Result.List {
-		closingTimestamp, _ := strconv.ParseInt(item[0], 10, 0)
-		openPrice, _ := strconv.ParseFloat(item[1], 64)
-		highPrice, _ := strconv.ParseFloat(item[2], 64)
-		lowPrice, _ := strconv.ParseFloat(item[3], 64)
-		closePrice, _ := strconv.ParseFloat(item[4], 64)
-		volume, _ := strconv.ParseFloat(item[5], 64)
-		turnover, _ := strconv.ParseFloat(item[6], 64)
+		if len(item) < 7 {
+			log.Errorf("cannot parse bybit kline %v", item)
+			continue
+		}
+		openingTimestamp, err := strconv.ParseUint(item[0], 10, 0)
+		if err != nil {
+			log.Errorf("cannot parse bybit kline %v %v", item, err)
+			continue
+		}
+		values, err := parseFloats(item[1:7]...)
+		if err != nil {
+			log.Errorf("cannot parse bybit kline %v %v", item, err)
+			continue
+		}
 		candles = append(candles, data.NewCandle(
 			symbol,
 			"",
-			60,
-			uint64(closingTimestamp),
-			uint64(closingTimestamp-60000),
-			openPrice,
-			closePrice,
-			highPrice,
-			lowPrice,
-			volume,
-			turnover,
+			interval,
+			openingTimestamp+interval*1000,
+			openingTimestamp,
+			values[0],
+			values[3],
+			values[1],
+			values[2],
+			values[4],
+			values[5],
 		))
 	}
-	return candles
+	return candles, nil
+}
+
+func parseFloats(values ...string) ([]float64, error) {
+	result := make([]float64, len(values))
+	for i, value := range values {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = f
+	}
+	return result, nil
 }
@@ -0,0 +1,155 @@
+package bybit
+
+import (
+	"candles-api/data"
+	"candles-api/testutil"
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_GetCandles(t *testing.T) {
+	from := time.UnixMilli(1670608800000)
+	klines := []*data.Candle{
+		data.NewCandle("BTCUSDT", "", 60, 1670608920000, 1670608860000, 17071, 17055.5, 17073, 17027, 268611, 15.74462667),
+		data.NewCandle("BTCUSDT", "", 60, 1670608860000, 1670608800000, 17071.5, 17071, 17071.5, 17061, 4177, 0.24469757),
+	}
+	tests := []struct {
+		name      string
+		to        time.Time
+		responses []testutil.Response
+		expected  []*data.Candle
+		requests  int
+		err       string
+	}{
+		{
+			name:      "klines",
+			to:        from.Add(time.Minute),
+			responses: []testutil.Response{testutil.OK(t, "bybit/klines.json")},
+			expected:  klines,
+			requests:  1,
+		},
+		{
+			name:      "pages",
+			to:        from.Add(time.Minute * 1500),
+			responses: []testutil.Response{testutil.OK(t, "bybit/klines.json"), testutil.OK(t, "bybit/empty.json")},
+			expected:  klines,
+			requests:  2,
+		},
+		{
+			name:      "empty list",
+			to:        from.Add(time.Minute),
+			responses: []testutil.Response{testutil.OK(t, "bybit/empty.json")},
+			expected:  []*data.Candle{},
+			requests:  1,
+		},
+		{
+			name:      "invalid symbol",
+			to:        from.Add(time.Minute),
+			responses: []testutil.Response{testutil.OK(t, "bybit/error.json")},
+			err:       "Symbol Is Invalid",
+		},
+		{
+			name:      "rate limited",
+			to:        from.Add(time.Minute),
+			responses: []testutil.Response{testutil.OK(t, "bybit/rate_limit.json")},
+			err:       "Too many visits!",
+		},
+		{
+			name:      "http 429",
+			to:        from.Add(time.Minute),
+			responses: []testutil.Response{{Status: http.StatusTooManyRequests}},
+			err:       "429 Too Many Requests",
+		},
+		{
+			name:      "malformed json",
+			to:        from.Add(time.Minute),
+			responses: []testutil.Response{testutil.OK(t, "malformed.json")},
+			err:       "unexpected end of JSON input",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := testutil.NewServer(t, test.responses...)
+			client := NewClient("").WithBaseUrl(server.URL).WithHttpClient(server.Client())
+			candles, err := client.GetCandles(context.Background(), "BTCUSDT", "", 60, from, test.to)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(candles, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, candles)
+			}
+			requests := server.Requests()
+			if len(requests) == 0 || len(requests) != test.requests {
+				t.Fatalf("expected %d requests, got %v", test.requests, requests)
+			}
+			query := requests[0].Query()
+			if requests[0].Path != "/v5/market/kline" || query.Get("symbol") != "BTCUSDT" || query.Get("interval") != "1" ||
+				query.Get("start") != "1670608800000" {
+				t.Fatalf("unexpected request %v", requests[0])
+			}
+		})
+	}
+}
+
+func TestParseKlines(t *testing.T) {
+	candles, err := parseKlines([]byte(testutil.Payload(t, "bybit/klines.json")), "BTCUSDT", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles, got %d", len(candles))
+	}
+	latest := candles[0]
+	if latest.OpeningTimestamp != 1670608860000 || latest.ClosingTimestamp != 1670608920000 {
+		t.Fatalf("expected the kline start as opening timestamp, got %+v", latest)
+	}
+	if latest.Id != "BTCUSDT_1670608920000_60" || latest.Interval != 60 {
+		t.Fatalf("unexpected candle %+v", latest)
+	}
+	if latest.Open != 17071 || latest.High != 17073 || latest.Low != 17027 || latest.Close != 17055.5 ||
+		latest.Volume != 268611 || latest.Turnover != 15.74462667 {
+		t.Fatalf("unexpected prices %+v", latest)
+	}
+}
+
+func TestParseKlines_HigherInterval(t *testing.T) {
+	candles, err := parseKlines([]byte(testutil.Payload(t, "bybit/klines.json")), "BTCUSDT", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if candles[1].OpeningTimestamp != 1670608800000 || candles[1].ClosingTimestamp != 1670612400000 {
+		t.Fatalf("unexpected bounds %+v", candles[1])
+	}
+}
+
+func TestParseKlines_Error(t *testing.T) {
+	_, err := parseKlines([]byte(`{"retCode":10001,"retMsg":"params error: symbol invalid","result":{}}`), "BTCUSDT", 60)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseKlines_SkipsMalformedRows(t *testing.T) {
+	payload := `{"retMsg":"OK","result":{"list":[
+		["1670608860000", "17071", "17073", "17027", "17055.5", "268611", "15.74462667"],
+		["1670608800000", "", "17071.5", "17061", "17071", "4177", "0.24469757"],
+		["1670608740000", "17071.5"]
+	]}}`
+	candles, err := parseKlines([]byte(payload), "BTCUSDT", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 1 || candles[0].OpeningTimestamp != 1670608860000 {
+		t.Fatalf("expected only the well-formed kline, got %+v", candles)
+	}
+}
@@ -0,0 +1,143 @@
+package bybit
+
+import (
+	"candles-api/data"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"github.com/gorilla/websocket"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultStreamUrl  = "wss://stream.bybit.com/v5/public/linear"
+	pingInterval      = time.Second * 20
+	readTimeout       = time.Minute
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+type klineMessage struct {
+	Topic string `json:"topic"`
+	Data  []struct {
+		Start    int64  `json:"start"`
+		End      int64  `json:"end"`
+		Interval string `json:"interval"`
+		Open     string `json:"open"`
+		Close    string `json:"close"`
+		High     string `json:"high"`
+		Low      string `json:"low"`
+		Volume   string `json:"volume"`
+		Turnover string `json:"turnover"`
+		Confirm  bool   `json:"confirm"`
+	} `json:"data"`
+	Op      string `json:"op"`
+	Success *bool  `json:"success"`
+	RetMsg  string `json:"ret_msg"`
+}
+
+func (c *Client) Stream(ctx context.Context, symbol string, onCandle func(*data.Candle), onConnect func()) error {
+	delay := minReconnectDelay
+	for {
+		connected, err := c.stream(ctx, symbol, onCandle, onConnect)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			delay = minReconnectDelay
+		}
+		log.Warnf("bybit stream %s disconnected: %v, reconnecting in %s", symbol, err, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+func (c *Client) stream(ctx context.Context, symbol string, onCandle func(*data.Candle), onConnect func()) (bool, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, c.streamUrl, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	var writeLock sync.Mutex
+	write := func(msg any) error {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+		return conn.WriteJSON(msg)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				_ = conn.Close()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				err := write(map[string]string{"op": "ping"})
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+	topic := fmt.Sprintf("kline.1.%s", symbol)
+	err = write(map[string]any{"op": "subscribe", "args": []string{topic}})
+	if err != nil {
+		return false, err
+	}
+	connected := false
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return connected, err
+		}
+		msg := &klineMessage{}
+		err = json.Unmarshal(raw, msg)
+		if err != nil {
+			log.Errorf("cannot parse bybit stream message %v", err)
+			continue
+		}
+		if msg.Op == "subscribe" {
+			if msg.Success == nil || !*msg.Success {
+				return connected, fmt.Errorf("subscribe to %s failed: %s", topic, msg.RetMsg)
+			}
+			connected = true
+			onConnect()
+			continue
+		}
+		if msg.Topic != topic {
+			continue
+		}
+		for _, item := range msg.Data {
+			values, err := parseFloats(item.Open, item.High, item.Low, item.Close, item.Volume, item.Turnover)
+			if err != nil {
+				log.Errorf("cannot parse bybit stream kline %+v %v", item, err)
+				continue
+			}
+			onCandle(data.NewCandle(
+				symbol,
+				"",
+				60,
+				uint64(item.Start)+60000,
+				uint64(item.Start),
+				values[0],
+				values[3],
+				values[1],
+				values[2],
+				values[4],
+				values[5],
+			))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package bybit
+
+import (
+	"candles-api/data"
+	"context"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const klinePayload = `{
+  "topic": "kline.1.BTCUSDT",
+  "type": "snapshot",
+  "ts": 1672324988882,
+  "data": [{
+    "start": 1672324980000,
+    "end": 1672325039999,
+    "interval": "1",
+    "open": "16649.5",
+    "close": "16677",
+    "high": "16677",
+    "low": "16608",
+    "volume": "2.081",
+    "turnover": "34666.4005",
+    "confirm": false,
+    "timestamp": 1672324988882
+  }]
+}`
+
+func newFakeStream(t *testing.T, connections *atomic.Int32) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		connections.Add(1)
+		req := struct {
+			Op   string   `json:"op"`
+			Args []string `json:"args"`
+		}{}
+		if err = conn.ReadJSON(&req); err != nil {
+			return
+		}
+		if req.Op != "subscribe" || len(req.Args) != 1 || req.Args[0] != "kline.1.BTCUSDT" {
+			t.Errorf("unexpected subscribe request %+v", req)
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"success":true,"ret_msg":"","op":"subscribe","conn_id":"1"}`))
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(klinePayload))
+		// drop the connection to force a reconnect
+	}))
+}
+
+func TestClient_Stream(t *testing.T) {
+	connections := &atomic.Int32{}
+	server := newFakeStream(t, connections)
+	defer server.Close()
+	client := NewClient("127.0.0.1").WithStreamUrl("ws" + strings.TrimPrefix(server.URL, "http"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	candles := make(chan *data.Candle, 10)
+	connects := &atomic.Int32{}
+	go func() {
+		_ = client.Stream(ctx, "BTCUSDT", func(candle *data.Candle) {
+			candles <- candle
+		}, func() {
+			connects.Add(1)
+		})
+	}()
+
+	select {
+	case candle := <-candles:
+		if candle.Symbol != "BTCUSDT" || candle.Interval != 60 || candle.Open != 16649.5 ||
+			candle.OpeningTimestamp != 1672324980000 || candle.ClosingTimestamp != 1672325040000 ||
+			candle.Close != 16677 || candle.High != 16677 || candle.Low != 16608 ||
+			candle.Volume != 2.081 || candle.Turnover != 34666.4005 {
+			t.Fatalf("unexpected candle %+v", candle)
+		}
+	case <-ctx.Done():
+		t.Fatal("no candle received")
+	}
+	for connects.Load() < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected a reconnect, got %d connects", connects.Load())
+		case <-time.After(time.Millisecond * 50):
+		}
+	}
+	cancel()
+	if connections.Load() < 2 {
+		t.Fatalf("expected at least 2 connections, got %d", connections.Load())
+	}
+}
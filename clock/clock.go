@@ -0,0 +1,133 @@
+package clock
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	// Every calls fn each interval until ctx is done, and returns then.
+	Every(ctx context.Context, interval time.Duration, fn func(ctx context.Context))
+}
+
+type system struct{}
+
+func System() Clock {
+	return system{}
+}
+
+func (system) Now() time.Time {
+	return time.Now()
+}
+
+func (system) Every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
+
+type shifted struct {
+	offset time.Duration
+}
+
+// StartingAt returns a clock that reads start now and from then on moves
+// with the wall clock, to run through a recorded timeline at real speed.
+func StartingAt(start time.Time) Clock {
+	return shifted{offset: time.Until(start)}
+}
+
+func (c shifted) Now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+func (c shifted) Every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	system{}.Every(ctx, interval, fn)
+}
+
+type job struct {
+	ctx      context.Context
+	interval time.Duration
+	next     time.Time
+	fn       func(ctx context.Context)
+}
+
+// Manual is a Clock that only moves when advanced. Scheduled functions run
+// on the goroutine calling Advance, so a pass has finished once it returns.
+type Manual struct {
+	now     time.Time
+	jobs    []*job
+	lock    sync.Mutex
+	changed *sync.Cond
+}
+
+func NewManual(now time.Time) *Manual {
+	m := &Manual{now: now}
+	m.changed = sync.NewCond(&m.lock)
+	return m
+}
+
+func (m *Manual) Now() time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.now
+}
+
+func (m *Manual) Every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	m.lock.Lock()
+	j := &job{ctx: ctx, interval: interval, next: m.now.Add(interval), fn: fn}
+	m.jobs = append(m.jobs, j)
+	m.changed.Broadcast()
+	m.lock.Unlock()
+	<-ctx.Done()
+	m.lock.Lock()
+	m.jobs = slices.DeleteFunc(m.jobs, func(other *job) bool {
+		return other == j
+	})
+	m.changed.Broadcast()
+	m.lock.Unlock()
+}
+
+// Advance moves the clock forward by d, running every scheduled function
+// that comes due on the way in order, each at its own due time.
+func (m *Manual) Advance(d time.Duration) {
+	m.lock.Lock()
+	target := m.now.Add(d)
+	for {
+		var due *job
+		for _, j := range m.jobs {
+			if j.ctx.Err() == nil && !j.next.After(target) && (due == nil || j.next.Before(due.next)) {
+				due = j
+			}
+		}
+		if due == nil {
+			break
+		}
+		m.now = due.next
+		due.next = due.next.Add(due.interval)
+		m.lock.Unlock()
+		due.fn(due.ctx)
+		m.lock.Lock()
+	}
+	m.now = target
+	m.lock.Unlock()
+}
+
+// BlockUntil waits until n functions are scheduled, so a test can advance
+// the clock knowing the loops it started are registered.
+func (m *Manual) BlockUntil(n int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for len(m.jobs) < n {
+		m.changed.Wait()
+	}
+}
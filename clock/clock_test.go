@@ -0,0 +1,53 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestManual_Advance(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewManual(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make([]string, 0)
+	done := make(chan struct{}, 2)
+	for _, scheduled := range []struct {
+		name     string
+		interval time.Duration
+	}{{"fast", time.Second * 2}, {"slow", time.Second * 5}} {
+		go func() {
+			m.Every(ctx, scheduled.interval, func(ctx context.Context) {
+				ran = append(ran, scheduled.name+"@"+m.Now().Sub(start).String())
+			})
+			done <- struct{}{}
+		}()
+	}
+	m.BlockUntil(2)
+
+	m.Advance(time.Second)
+	if len(ran) != 0 {
+		t.Fatalf("expected nothing to be due yet, got %v", ran)
+	}
+	m.Advance(time.Second * 5)
+	expected := []string{"fast@2s", "fast@4s", "slow@5s", "fast@6s"}
+	if len(ran) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ran)
+	}
+	for i := range expected {
+		if ran[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, ran)
+		}
+	}
+	if !m.Now().Equal(start.Add(time.Second * 6)) {
+		t.Fatalf("unexpected time %s", m.Now())
+	}
+
+	cancel()
+	<-done
+	<-done
+	m.Advance(time.Minute)
+	if len(ran) != len(expected) {
+		t.Fatalf("expected cancelled functions not to run, got %v", ran)
+	}
+}
@@ -0,0 +1,299 @@
+package config
+
+import (
+	"bytes"
+	"candles-api/schedule"
+	"candles-api/store"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PathEnv     = "CANDLES_CONFIG"
+	DefaultPath = "config.yaml"
+)
+
+type IntervalEntry struct {
+	Seconds   uint64 `json:"seconds" yaml:"seconds" toml:"seconds"`
+	Retention string `json:"retention" yaml:"retention" toml:"retention"`
+}
+
+type SourceEntry struct {
+	Source       string  `json:"source" yaml:"source" toml:"source"`
+	Symbol       string  `json:"symbol" yaml:"symbol" toml:"symbol"`
+	MicCode      string  `json:"micCode,omitempty" yaml:"micCode,omitempty" toml:"micCode,omitempty"`
+	Weight       float64 `json:"weight,omitempty" yaml:"weight,omitempty" toml:"weight,omitempty"`
+	MaxDeviation float64 `json:"maxDeviation,omitempty" yaml:"maxDeviation,omitempty" toml:"maxDeviation,omitempty"`
+}
+
+type MarketEntry struct {
+	MarketId       string         `json:"marketId" yaml:"marketId" toml:"marketId"`
+	Source         string         `json:"source,omitempty" yaml:"source,omitempty" toml:"source,omitempty"`
+	Symbol         string         `json:"symbol,omitempty" yaml:"symbol,omitempty" toml:"symbol,omitempty"`
+	MicCode        string         `json:"micCode,omitempty" yaml:"micCode,omitempty" toml:"micCode,omitempty"`
+	Schedule       string         `json:"schedule,omitempty" yaml:"schedule,omitempty" toml:"schedule,omitempty"`
+	Sources        []*SourceEntry `json:"sources,omitempty" yaml:"sources,omitempty" toml:"sources,omitempty"`
+	MinSources     int            `json:"minSources,omitempty" yaml:"minSources,omitempty" toml:"minSources,omitempty"`
+	Fallbacks      []*SourceEntry `json:"fallbacks,omitempty" yaml:"fallbacks,omitempty" toml:"fallbacks,omitempty"`
+	StaleAfter     string         `json:"staleAfter,omitempty" yaml:"staleAfter,omitempty" toml:"staleAfter,omitempty"`
+	MaxJumpPercent float64        `json:"maxJumpPercent,omitempty" yaml:"maxJumpPercent,omitempty" toml:"maxJumpPercent,omitempty"`
+	MaxJumpStdDevs float64        `json:"maxJumpStdDevs,omitempty" yaml:"maxJumpStdDevs,omitempty" toml:"maxJumpStdDevs,omitempty"`
+}
+
+type File struct {
+	Intervals []*IntervalEntry `json:"intervals" yaml:"intervals" toml:"intervals"`
+	Markets   []*MarketEntry   `json:"markets" yaml:"markets" toml:"markets"`
+}
+
+type Config struct {
+	Intervals []*store.Interval
+	Markets   []*store.Config
+}
+
+func Path(flagValue string) string {
+	if len(flagValue) > 0 {
+		return flagValue
+	}
+	if env := os.Getenv(PathEnv); len(env) > 0 {
+		return env
+	}
+	return DefaultPath
+}
+
+func Load(path string, sources []string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config %s: %v", path, err)
+	}
+	file, err := decode(path, raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config %s: %v", path, err)
+	}
+	cfg, err := file.Validate(sources)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s:\n%v", path, err)
+	}
+	return cfg, nil
+}
+
+func decode(path string, raw []byte) (*File, error) {
+	file := &File{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		return file, decoder.Decode(file)
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		return file, decoder.Decode(file)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		return file, decoder.Decode(file)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", filepath.Ext(path))
+	}
+}
+
+func (f *File) Validate(sources []string) (*Config, error) {
+	problems := make([]string, 0)
+	cfg := &Config{
+		Intervals: make([]*store.Interval, 0, len(f.Intervals)),
+		Markets:   make([]*store.Config, 0, len(f.Markets)),
+	}
+	if len(f.Intervals) == 0 {
+		problems = append(problems, "intervals: at least one interval is required")
+	}
+	seenIntervals := map[uint64]bool{}
+	for i, entry := range f.Intervals {
+		prefix := fmt.Sprintf("intervals[%d]", i)
+		if entry == nil {
+			problems = append(problems, fmt.Sprintf("%s: empty entry", prefix))
+			continue
+		}
+		if entry.Seconds == 0 || entry.Seconds%60 != 0 {
+			problems = append(problems, fmt.Sprintf("%s: seconds must be a positive multiple of 60, got %d", prefix, entry.Seconds))
+		} else if seenIntervals[entry.Seconds] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate interval %d", prefix, entry.Seconds))
+		}
+		seenIntervals[entry.Seconds] = true
+		retention, err := ParseRetention(entry.Retention)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+		}
+		cfg.Intervals = append(cfg.Intervals, &store.Interval{
+			Seconds:   entry.Seconds,
+			Retention: retention,
+		})
+	}
+	if len(f.Intervals) > 0 && !seenIntervals[60] {
+		problems = append(problems, "intervals: the 60 second interval is required for aggregation")
+	}
+	if len(f.Markets) == 0 {
+		problems = append(problems, "markets: at least one market is required")
+	}
+	seenMarkets := map[string]bool{}
+	for i, entry := range f.Markets {
+		prefix := fmt.Sprintf("markets[%d]", i)
+		if entry == nil {
+			problems = append(problems, fmt.Sprintf("%s: empty entry", prefix))
+			continue
+		}
+		if len(entry.Symbol) > 0 {
+			prefix = fmt.Sprintf("%s (%s)", prefix, entry.Symbol)
+		} else if len(entry.Sources) > 0 && entry.Sources[0] != nil && len(entry.Sources[0].Symbol) > 0 {
+			prefix = fmt.Sprintf("%s (%s)", prefix, entry.Sources[0].Symbol)
+		}
+		if decoded, err := hex.DecodeString(entry.MarketId); err != nil || len(decoded) != 32 {
+			problems = append(problems, fmt.Sprintf("%s: marketId must be 64 hex characters, got %q", prefix, entry.MarketId))
+		} else if seenMarkets[entry.MarketId] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate marketId %s", prefix, entry.MarketId))
+		}
+		seenMarkets[entry.MarketId] = true
+		market := &store.Config{
+			MarketId:       entry.MarketId,
+			PriceSource:    store.PriceSource(entry.Source),
+			Symbol:         entry.Symbol,
+			MicCode:        entry.MicCode,
+			Schedule:       entry.Schedule,
+			MinSources:     entry.MinSources,
+			MaxJumpPercent: entry.MaxJumpPercent,
+			MaxJumpStdDevs: entry.MaxJumpStdDevs,
+		}
+		if entry.MaxJumpPercent < 0 {
+			problems = append(problems, fmt.Sprintf("%s: maxJumpPercent must not be negative", prefix))
+		}
+		if entry.MaxJumpStdDevs < 0 {
+			problems = append(problems, fmt.Sprintf("%s: maxJumpStdDevs must not be negative", prefix))
+		}
+		if len(entry.Sources) == 0 {
+			problems = append(problems, validateSource(prefix, sources, entry.Source, entry.Symbol)...)
+		} else {
+			if len(entry.Source) > 0 || len(entry.Symbol) > 0 || len(entry.MicCode) > 0 {
+				problems = append(problems, fmt.Sprintf("%s: use either source/symbol/micCode or sources, not both", prefix))
+			}
+			if entry.MinSources < 0 || entry.MinSources > len(entry.Sources) {
+				problems = append(problems, fmt.Sprintf("%s: minSources must be between 0 and %d, got %d", prefix, len(entry.Sources), entry.MinSources))
+			}
+			for j, source := range entry.Sources {
+				sourcePrefix := fmt.Sprintf("%s.sources[%d]", prefix, j)
+				if source == nil {
+					problems = append(problems, fmt.Sprintf("%s: empty entry", sourcePrefix))
+					continue
+				}
+				problems = append(problems, validateSource(sourcePrefix, sources, source.Source, source.Symbol)...)
+				if source.Weight < 0 {
+					problems = append(problems, fmt.Sprintf("%s: weight must not be negative", sourcePrefix))
+				}
+				if source.MaxDeviation < 0 {
+					problems = append(problems, fmt.Sprintf("%s: maxDeviation must not be negative", sourcePrefix))
+				}
+				weight := source.Weight
+				if weight == 0 {
+					weight = 1
+				}
+				market.Sources = append(market.Sources, &store.Source{
+					PriceSource:  store.PriceSource(source.Source),
+					Symbol:       source.Symbol,
+					MicCode:      source.MicCode,
+					Weight:       weight,
+					MaxDeviation: source.MaxDeviation,
+				})
+			}
+			if len(market.Sources) > 0 {
+				market.PriceSource = market.Sources[0].PriceSource
+				market.Symbol = market.Sources[0].Symbol
+				market.MicCode = market.Sources[0].MicCode
+			}
+		}
+		if len(entry.Fallbacks) > 0 && len(entry.Sources) > 1 {
+			problems = append(problems, fmt.Sprintf("%s: fallbacks cannot be combined with multiple sources", prefix))
+		}
+		seen := map[string]bool{}
+		for _, source := range market.AllSources() {
+			seen[source.Key()] = true
+		}
+		for j, fallback := range entry.Fallbacks {
+			fallbackPrefix := fmt.Sprintf("%s.fallbacks[%d]", prefix, j)
+			if fallback == nil {
+				problems = append(problems, fmt.Sprintf("%s: empty entry", fallbackPrefix))
+				continue
+			}
+			problems = append(problems, validateSource(fallbackPrefix, sources, fallback.Source, fallback.Symbol)...)
+			source := &store.Source{
+				PriceSource: store.PriceSource(fallback.Source),
+				Symbol:      fallback.Symbol,
+				MicCode:     fallback.MicCode,
+				Weight:      1,
+			}
+			if seen[source.Key()] {
+				problems = append(problems, fmt.Sprintf("%s: duplicate source %s", fallbackPrefix, source.Key()))
+			}
+			seen[source.Key()] = true
+			market.Fallbacks = append(market.Fallbacks, source)
+		}
+		if len(entry.StaleAfter) > 0 {
+			staleAfter, err := time.ParseDuration(entry.StaleAfter)
+			if err != nil || staleAfter <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: invalid staleAfter %q, expected a positive duration like 5m", prefix, entry.StaleAfter))
+			}
+			market.StaleAfter = staleAfter
+		}
+		if _, err := schedule.Parse(entry.Schedule); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+		}
+		cfg.Markets = append(cfg.Markets, market)
+	}
+	if len(problems) > 0 {
+		errs := make([]error, 0, len(problems))
+		for _, problem := range problems {
+			errs = append(errs, fmt.Errorf("  - %s", problem))
+		}
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+func validateSource(prefix string, sources []string, source string, symbol string) []string {
+	problems := make([]string, 0)
+	if !slices.Contains(sources, source) {
+		problems = append(problems, fmt.Sprintf("%s: unknown source %q, expected one of %s", prefix, source, strings.Join(sources, ", ")))
+	}
+	if len(symbol) == 0 {
+		problems = append(problems, fmt.Sprintf("%s: symbol required", prefix))
+	}
+	return problems
+}
+
+func ParseRetention(value string) (time.Duration, error) {
+	if len(value) == 0 {
+		return 0, errors.New("retention required")
+	}
+	var retention time.Duration
+	var err error
+	if strings.HasSuffix(value, "d") {
+		var days int64
+		days, err = strconv.ParseInt(strings.TrimSuffix(value, "d"), 10, 0)
+		retention = time.Hour * 24 * time.Duration(days)
+	} else {
+		retention, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("retention format invalid %q, expected e.g. 7d or 12h", value)
+	}
+	if retention <= 0 {
+		return 0, fmt.Errorf("retention must be positive, got %q", value)
+	}
+	return retention, nil
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var sources = []string{"bybit", "polygon", "twelve-data"}
+
+func writeConfig(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Yaml(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+intervals:
+  - seconds: 60
+    retention: 7d
+  - seconds: 3600
+    retention: 36h
+markets:
+  - marketId: 6d8da2600e94db28a0ff024049d8c1fe1e6d26ba46fd3e43517f26c133caad93
+    source: bybit
+    symbol: BTCUSDT
+`)
+	cfg, err := Load(path, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Intervals) != 2 || cfg.Intervals[0].Retention != time.Hour*24*7 || cfg.Intervals[1].Retention != time.Hour*36 {
+		t.Fatalf("unexpected intervals %+v", cfg.Intervals)
+	}
+	if len(cfg.Markets) != 1 || cfg.Markets[0].Symbol != "BTCUSDT" || cfg.Markets[0].PriceSource != "bybit" {
+		t.Fatalf("unexpected markets %+v", cfg.Markets)
+	}
+}
+
+func TestLoad_Json(t *testing.T) {
+	path := writeConfig(t, "config.json", `{
+  "intervals": [{"seconds": 60, "retention": "7d"}],
+  "markets": [{"marketId": "90cbdea8d4986173b2fbcbbec1fe7565e7fc1e3aa60b3ccb0e9d1a5a9eb18f19", "source": "twelve-data", "symbol": "W_1", "micCode": "COMMODITY"}]
+}`)
+	cfg, err := Load(path, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Markets[0].MicCode != "COMMODITY" {
+		t.Fatalf("unexpected markets %+v", cfg.Markets)
+	}
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+intervals:
+  - seconds: 90
+    retention: 7d
+  - seconds: 300
+    retention: forever
+markets:
+  - marketId: abc
+    source: binance
+    symbol: BTCUSDT
+  - marketId: 6d8da2600e94db28a0ff024049d8c1fe1e6d26ba46fd3e43517f26c133caad93
+    source: bybit
+  - marketId: 6d8da2600e94db28a0ff024049d8c1fe1e6d26ba46fd3e43517f26c133caad93
+    source: bybit
+    symbol: ETHUSDT
+    schedule: weekdays
+`)
+	_, err := Load(path, sources)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, expected := range []string{
+		"intervals[0]: seconds must be a positive multiple of 60",
+		"intervals[1]: retention format invalid",
+		"the 60 second interval is required",
+		"markets[0] (BTCUSDT): marketId must be 64 hex characters",
+		`markets[0] (BTCUSDT): unknown source "binance"`,
+		"markets[1]: symbol required",
+		"markets[2] (ETHUSDT): duplicate marketId",
+		`markets[2] (ETHUSDT): schedule "weekdays"`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in error:\n%v", expected, err)
+		}
+	}
+}
+
+func TestLoad_RejectsUnknownFields(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+intervals:
+  - seconds: 60
+    retention: 7d
+markets:
+  - marketId: 6d8da2600e94db28a0ff024049d8c1fe1e6d26ba46fd3e43517f26c133caad93
+    source: bybit
+    symbol: BTCUSDT
+    mic: XLON
+`)
+	if _, err := Load(path, sources); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLoad_Repository(t *testing.T) {
+	if _, err := Load(filepath.Join("..", DefaultPath), sources); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_ConsensusSources(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+intervals:
+  - seconds: 60
+    retention: 7d
+markets:
+  - marketId: 778e7f4cd2414faf44d1e8a5391bbec87616aef5798bb2093f2db56704543c5f
+    schedule: "24/5"
+    minSources: 2
+    sources:
+      - source: polygon
+        symbol: EUR-USD
+        maxDeviation: 0.5
+      - source: twelve-data
+        symbol: EUR/USD
+        weight: 2
+  - marketId: 6d8da2600e94db28a0ff024049d8c1fe1e6d26ba46fd3e43517f26c133caad93
+    source: bybit
+    symbol: BTCUSDT
+    minSources: 3
+    sources:
+      - source: bybit
+        symbol: BTCUSDT
+`)
+	_, err := Load(path, sources)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, expected := range []string{
+		"markets[1] (BTCUSDT): use either source/symbol/micCode or sources",
+		"markets[1] (BTCUSDT): minSources must be between 0 and 1",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in error:\n%v", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "markets[0]") {
+		t.Errorf("expected markets[0] to be valid:\n%v", err)
+	}
+
+	path = writeConfig(t, "valid.yaml", strings.Split(mustRead(t, path), "  - marketId: 6d8d")[0])
+	cfg, err := Load(path, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	market := cfg.Markets[0]
+	if !market.IsConsensus() || market.PriceSource != "polygon" || market.Symbol != "EUR-USD" || market.MinSources != 2 {
+		t.Fatalf("unexpected market %+v", market)
+	}
+	if market.Sources[0].MaxDeviation != 0.5 || market.Sources[0].Weight != 1 || market.Sources[1].Weight != 2 {
+		t.Fatalf("unexpected sources %+v %+v", market.Sources[0], market.Sources[1])
+	}
+}
+
+func mustRead(t *testing.T, path string) string {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestLoad_Fallbacks(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+intervals:
+  - seconds: 60
+    retention: 7d
+markets:
+  - marketId: 778e7f4cd2414faf44d1e8a5391bbec87616aef5798bb2093f2db56704543c5f
+    source: polygon
+    symbol: EUR-USD
+    staleAfter: 3m
+    fallbacks:
+      - source: twelve-data
+        symbol: EUR/USD
+`)
+	cfg, err := Load(path, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	market := cfg.Markets[0]
+	if len(market.Fallbacks) != 1 || market.Fallbacks[0].Key() != "twelve-data:EUR/USD" || market.StaleAfter != 3*time.Minute {
+		t.Fatalf("unexpected market %+v", market)
+	}
+
+	path = writeConfig(t, "invalid.yaml", `
+intervals:
+  - seconds: 60
+    retention: 7d
+markets:
+  - marketId: 778e7f4cd2414faf44d1e8a5391bbec87616aef5798bb2093f2db56704543c5f
+    source: polygon
+    symbol: EUR-USD
+    staleAfter: soon
+    fallbacks:
+      - source: polygon
+        symbol: EUR-USD
+      - source: finnhub
+        symbol: EUR/USD
+`)
+	_, err = Load(path, sources)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, expected := range []string{
+		"markets[0] (EUR-USD): invalid staleAfter \"soon\"",
+		"markets[0] (EUR-USD).fallbacks[0]: duplicate source polygon:EUR-USD",
+		"markets[0] (EUR-USD).fallbacks[1]: unknown source \"finnhub\"",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in error:\n%v", expected, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"context"
+	"github.com/charmbracelet/log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const WatchInterval = time.Second * 5
+
+func Watch(ctx context.Context, path string, sources []string, onChange func(*Config)) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	modified := modTime(path)
+	go func() {
+		ticker := time.NewTicker(WatchInterval)
+		defer ticker.Stop()
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				log.Infof("received SIGHUP, reloading config %s", path)
+			case <-ticker.C:
+				current := modTime(path)
+				if current.Equal(modified) {
+					continue
+				}
+				log.Infof("config %s changed, reloading", path)
+			}
+			modified = modTime(path)
+			cfg, err := Load(path, sources)
+			if err != nil {
+				log.Errorf("keeping previous config: %v", err)
+				continue
+			}
+			onChange(cfg)
+		}
+	}()
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -1,20 +1,28 @@
 package data
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+)
 
+// Candle covers the half-open range [OpeningTimestamp, ClosingTimestamp) in
+// unix milliseconds, so OpeningTimestamp is the bar start reported by the
+// providers and ClosingTimestamp is OpeningTimestamp + Interval seconds.
+// Candles are keyed by ClosingTimestamp.
 type Candle struct {
-	Id               string  `json:"id,omitempty"`
-	Symbol           string  `json:"symbol,omitempty"`
-	MarketId         string  `json:"marketId,omitempty"`
-	Interval         uint64  `json:"interval,omitempty"`
-	ClosingTimestamp uint64  `json:"closingTimestamp,omitempty"`
-	OpeningTimestamp uint64  `json:"openingTimestamp,omitempty"`
-	Open             float64 `json:"open,omitempty"`
-	Close            float64 `json:"close,omitempty"`
-	High             float64 `json:"high,omitempty"`
-	Low              float64 `json:"low,omitempty"`
-	Volume           float64 `json:"volume,omitempty"`
-	Turnover         float64 `json:"turnover,omitempty"`
+	Id               string   `json:"id,omitempty"`
+	Symbol           string   `json:"symbol,omitempty"`
+	MarketId         string   `json:"marketId,omitempty"`
+	Interval         uint64   `json:"interval,omitempty"`
+	ClosingTimestamp uint64   `json:"closingTimestamp,omitempty"`
+	OpeningTimestamp uint64   `json:"openingTimestamp,omitempty"`
+	Open             float64  `json:"open,omitempty"`
+	Close            float64  `json:"close,omitempty"`
+	High             float64  `json:"high,omitempty"`
+	Low              float64  `json:"low,omitempty"`
+	Volume           float64  `json:"volume,omitempty"`
+	Turnover         float64  `json:"turnover,omitempty"`
+	Sources          []string `json:"sources,omitempty"`
 }
 
 func NewCandle(
@@ -45,3 +53,19 @@ func NewCandle(
 		Turnover:         turnover,
 	}
 }
+
+func (c *Candle) Equal(other *Candle) bool {
+	return c.Id == other.Id &&
+		c.Symbol == other.Symbol &&
+		c.MarketId == other.MarketId &&
+		c.Interval == other.Interval &&
+		c.ClosingTimestamp == other.ClosingTimestamp &&
+		c.OpeningTimestamp == other.OpeningTimestamp &&
+		c.Open == other.Open &&
+		c.Close == other.Close &&
+		c.High == other.High &&
+		c.Low == other.Low &&
+		c.Volume == other.Volume &&
+		c.Turnover == other.Turnover &&
+		slices.Equal(c.Sources, other.Sources)
+}
@@ -0,0 +1,280 @@
+package journal
+
+import (
+	"bufio"
+	"candles-api/data"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+const (
+	snapshotFile = "snapshot.jsonl"
+	logFile      = "journal.jsonl"
+	lockFile     = "LOCK"
+	rotatedFile  = "journal.old.jsonl"
+)
+
+// Version 0 entries predate the fix that made OpeningTimestamp the provider
+// bar start, they are migrated on replay.
+const Version = 1
+
+// ErrLocked is returned by Open when another process holds the journal.
+var ErrLocked = errors.New("journal is in use by another process")
+
+type Op string
+
+const (
+	Save   Op = "save"
+	Remove Op = "remove"
+)
+
+type Entry struct {
+	Version int          `json:"v,omitempty"`
+	Op      Op           `json:"op"`
+	Candle  *data.Candle `json:"candle"`
+}
+
+type Journal struct {
+	dir    string
+	lock   *os.File
+	file   *os.File
+	writer *bufio.Writer
+	mutex  sync.Mutex
+}
+
+// Exists reports whether dir already holds a journal or a snapshot.
+func Exists(dir string) (bool, error) {
+	for _, name := range []string{snapshotFile, rotatedFile, logFile} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return false, fmt.Errorf("cannot inspect journal %s: %v", dir, err)
+		}
+	}
+	return false, nil
+}
+
+func Open(dir string) (*Journal, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create journal dir %s: %v", dir, err)
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open journal lock %s: %v", dir, err)
+	}
+	err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		_ = lock.Close()
+		return nil, fmt.Errorf("%w, %s: %v", ErrLocked, dir, err)
+	}
+	file, err := os.OpenFile(filepath.Join(dir, logFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err == nil {
+		err = terminateTornLine(file)
+	}
+	if err != nil {
+		_ = lock.Close()
+		return nil, fmt.Errorf("cannot open journal %s: %v", dir, err)
+	}
+	return &Journal{
+		dir:    dir,
+		lock:   lock,
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}, nil
+}
+
+func (j *Journal) Append(op Op, candle *data.Candle) error {
+	line, err := json.Marshal(&Entry{Version: Version, Op: op, Candle: candle})
+	if err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	_, err = j.writer.Write(append(line, '\n'))
+	return err
+}
+
+func (j *Journal) Flush() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	err := j.writer.Flush()
+	if err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// Rotate moves the current log aside so that a snapshot taken at the same
+// point in time can replace it. It must be called while no candles are being
+// appended, otherwise those writes could land in neither file.
+func (j *Journal) Rotate() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	err := j.writer.Flush()
+	if err != nil {
+		return err
+	}
+	err = j.file.Close()
+	if err != nil {
+		return err
+	}
+	// a previous snapshot may have failed after rotating, its entries are
+	// still needed so they are merged into the new rotated file
+	err = appendFile(filepath.Join(j.dir, rotatedFile), filepath.Join(j.dir, logFile))
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(j.dir, logFile), os.O_CREATE|os.O_TRUNC|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	j.file = file
+	j.writer.Reset(file)
+	return nil
+}
+
+// Snapshot writes the full candle set captured at the last Rotate and drops
+// the rotated log it supersedes.
+func (j *Journal) Snapshot(candles []*data.Candle) error {
+	tmp := filepath.Join(j.dir, snapshotFile+".tmp")
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, candle := range candles {
+		err = encoder.Encode(&Entry{Version: Version, Op: Save, Candle: candle})
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	err = writer.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, filepath.Join(j.dir, snapshotFile))
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(j.dir, rotatedFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (j *Journal) Replay(apply func(op Op, candle *data.Candle)) (int, error) {
+	count := 0
+	for _, name := range []string{snapshotFile, rotatedFile, logFile} {
+		n, err := replayFile(filepath.Join(j.dir, name), apply)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+func (j *Journal) Close() error {
+	err := j.Flush()
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := j.lock.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func replayFile(path string, apply func(op Op, candle *data.Candle)) (int, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	count := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		entry := &Entry{}
+		err = json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil || entry.Candle == nil {
+			// a crash mid-write leaves a torn last line, which is safe to skip
+			log.Warnf("skipping unreadable journal entry %s:%d", path, line)
+			continue
+		}
+		if entry.Version == 0 {
+			migrate(entry.Candle)
+		}
+		apply(entry.Op, entry.Candle)
+		count++
+	}
+	return count, scanner.Err()
+}
+
+func appendFile(dst string, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func terminateTornLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	reader, err := os.Open(file.Name())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = reader.ReadAt(last, info.Size()-1)
+	if err != nil || last[0] == '\n' {
+		return err
+	}
+	_, err = file.Write([]byte{'\n'})
+	return err
+}
+
+func migrate(candle *data.Candle) {
+	if candle.Interval == 60 {
+		candle.OpeningTimestamp = candle.ClosingTimestamp
+		candle.ClosingTimestamp += 60000
+	} else {
+		candle.OpeningTimestamp = candle.ClosingTimestamp - candle.Interval*1000
+	}
+	candle.Id = fmt.Sprintf("%s_%d_%d", candle.Symbol, candle.ClosingTimestamp, candle.Interval)
+}
@@ -0,0 +1,163 @@
+package journal
+
+import (
+	"candles-api/data"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func replayAll(t *testing.T, j *Journal) map[string]*data.Candle {
+	candles := map[string]*data.Candle{}
+	_, err := j.Replay(func(op Op, candle *data.Candle) {
+		if op == Remove {
+			delete(candles, candle.Id)
+		} else {
+			candles[candle.Id] = candle
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return candles
+}
+
+func TestJournal_ReplayAfterSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 1, 2, 3, 0.5, 10, 20)
+	second := data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 2, 3, 4, 1.5, 10, 20)
+	third := data.NewCandle("BTCUSDT", "btc", 60, 180000, 120000, 3, 4, 5, 2.5, 10, 20)
+	_ = j.Append(Save, first)
+	_ = j.Append(Save, second)
+	if err = j.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err = j.Snapshot([]*data.Candle{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	_ = j.Append(Remove, first)
+	_ = j.Append(Save, third)
+	if err = j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	candles := replayAll(t, j)
+	if len(candles) != 2 || candles[second.Id] == nil || candles[third.Id] == nil {
+		t.Fatalf("unexpected candles %v", candles)
+	}
+	if !candles[third.Id].Equal(third) {
+		t.Fatalf("expected %+v, got %+v", third, candles[third.Id])
+	}
+}
+
+func TestJournal_ReplayAfterFailedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 1, 2, 3, 0.5, 10, 20)
+	second := data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 2, 3, 4, 1.5, 10, 20)
+	_ = j.Append(Save, first)
+	if err = j.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	_ = j.Append(Save, second)
+	if err = j.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	_ = j.Close()
+
+	j, _ = Open(dir)
+	defer j.Close()
+	if candles := replayAll(t, j); len(candles) != 2 {
+		t.Fatalf("expected both candles to survive, got %v", candles)
+	}
+}
+
+func TestJournal_SkipsTornLine(t *testing.T) {
+	dir := t.TempDir()
+	j, _ := Open(dir)
+	_ = j.Append(Save, data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 1, 2, 3, 0.5, 10, 20))
+	_ = j.Close()
+	file, _ := os.OpenFile(filepath.Join(dir, logFile), os.O_APPEND|os.O_WRONLY, 0o644)
+	_, _ = file.WriteString(`{"op":"save","candle":{"id":"BTC`)
+	_ = file.Close()
+
+	j, _ = Open(dir)
+	_ = j.Append(Save, data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 1, 2, 3, 0.5, 10, 20))
+	_ = j.Close()
+
+	j, _ = Open(dir)
+	defer j.Close()
+	if candles := replayAll(t, j); len(candles) != 2 {
+		t.Fatalf("expected 2 candles, got %v", candles)
+	}
+}
+
+func TestJournal_ExclusiveLock(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open(dir); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected second open to fail while the journal is in use, got %v", err)
+	}
+	_ = j.Close()
+	j, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = j.Close()
+}
+
+func TestExists(t *testing.T) {
+	dir := t.TempDir()
+	if exists, err := Exists(filepath.Join(dir, "missing")); err != nil || exists {
+		t.Fatalf("expected a missing dir to hold no journal, got %v %v", exists, err)
+	}
+	if exists, err := Exists(dir); err != nil || exists {
+		t.Fatalf("expected an empty dir to hold no journal, got %v %v", exists, err)
+	}
+	j, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = j.Close()
+	if exists, err := Exists(dir); err != nil || !exists {
+		t.Fatalf("expected an opened journal to exist, got %v %v", exists, err)
+	}
+}
+
+func TestJournal_MigratesVersion0(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, logFile), []byte(
+		`{"op":"save","candle":{"id":"BTCUSDT_120000_60","symbol":"BTCUSDT","marketId":"btc","interval":60,"closingTimestamp":120000,"openingTimestamp":60000,"open":1}}
+{"op":"save","candle":{"id":"BTCUSDT_300000_300","symbol":"BTCUSDT","marketId":"btc","interval":300,"closingTimestamp":300000,"openingTimestamp":60000,"open":1}}
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, _ := Open(dir)
+	defer j.Close()
+	candles := replayAll(t, j)
+	minute := candles["BTCUSDT_180000_60"]
+	if minute == nil || minute.OpeningTimestamp != 120000 || minute.ClosingTimestamp != 180000 {
+		t.Fatalf("unexpected migrated 1m candle %+v", candles)
+	}
+	five := candles["BTCUSDT_300000_300"]
+	if five == nil || five.OpeningTimestamp != 0 || five.ClosingTimestamp != 300000 {
+		t.Fatalf("unexpected migrated 5m candle %+v", candles)
+	}
+}
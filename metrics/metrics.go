@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+const namespace = "candles"
+
+var (
+	ProviderRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_requests_total",
+		Help:      "Requests made to price providers.",
+	}, []string{"provider", "operation"})
+	ProviderErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_errors_total",
+		Help:      "Requests to price providers that returned an error.",
+	}, []string{"provider", "operation"})
+	ProviderLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Latency of requests to price providers, including paging.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"provider", "operation"})
+	LastCandleAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "market_last_candle_age_seconds",
+		Help:      "Seconds since the closing timestamp of the newest 1m candle of a market.",
+	}, []string{"market", "symbol"})
+	StoredCandles = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stored_candles",
+		Help:      "Candles held in the store.",
+	}, []string{"market", "interval"})
+	PassDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pass_duration_seconds",
+		Help:      "Duration of archive and aggregate passes.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"pass"})
+	LockWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_lock_wait_seconds",
+		Help:      "Time spent waiting to acquire the store's candles lock.",
+		Buckets:   prometheus.ExponentialBuckets(0.000001, 4, 12),
+	})
+	HttpLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of API requests per route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func Since(started time.Time) float64 {
+	return time.Since(started).Seconds()
+}
+
+type instrumented struct {
+	provider.Provider
+}
+
+type instrumentedStreamer struct {
+	*instrumented
+	provider.Streamer
+}
+
+// Instrument wraps a provider so its requests are counted and timed. A
+// provider that also streams keeps its provider.Streamer implementation.
+func Instrument(p provider.Provider) provider.Provider {
+	wrapped := &instrumented{Provider: p}
+	if streamer, ok := p.(provider.Streamer); ok {
+		return &instrumentedStreamer{instrumented: wrapped, Streamer: streamer}
+	}
+	return wrapped
+}
+
+func (p *instrumented) observe(operation string, started time.Time, err error) {
+	ProviderRequests.WithLabelValues(p.Name(), operation).Inc()
+	ProviderLatency.WithLabelValues(p.Name(), operation).Observe(Since(started))
+	if err != nil {
+		ProviderErrors.WithLabelValues(p.Name(), operation).Inc()
+	}
+}
+
+func (p *instrumented) GetLatestCandles(ctx context.Context, symbol string, micCode string) ([]*data.Candle, error) {
+	started := time.Now()
+	candles, err := p.Provider.GetLatestCandles(ctx, symbol, micCode)
+	p.observe("latest", started, err)
+	return candles, err
+}
+
+func (p *instrumented) GetCandles(ctx context.Context, symbol string, micCode string, interval uint64, from time.Time, to time.Time) ([]*data.Candle, error) {
+	started := time.Now()
+	candles, err := p.Provider.GetCandles(ctx, symbol, micCode, interval, from, to)
+	p.observe("range", started, err)
+	return candles, err
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeProvider struct {
+	err error
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) Intervals() []uint64 {
+	return []uint64{60}
+}
+
+func (p *fakeProvider) PollInterval() time.Duration {
+	return time.Second
+}
+
+func (p *fakeProvider) GetLatestCandles(context.Context, string, string) ([]*data.Candle, error) {
+	return nil, p.err
+}
+
+func (p *fakeProvider) GetCandles(context.Context, string, string, uint64, time.Time, time.Time) ([]*data.Candle, error) {
+	return nil, p.err
+}
+
+type fakeStreamer struct {
+	fakeProvider
+}
+
+func (p *fakeStreamer) Stream(context.Context, string, func(*data.Candle), func()) error {
+	return nil
+}
+
+func TestInstrument(t *testing.T) {
+	fake := &fakeProvider{}
+	p := Instrument(fake)
+	_, _ = p.GetLatestCandles(context.Background(), "BTCUSDT", "")
+	fake.err = errors.New("unavailable")
+	_, _ = p.GetLatestCandles(context.Background(), "BTCUSDT", "")
+	_, _ = p.GetCandles(context.Background(), "BTCUSDT", "", 60, time.Now(), time.Now())
+
+	if n := testutil.ToFloat64(ProviderRequests.WithLabelValues("fake", "latest")); n != 2 {
+		t.Fatalf("expected 2 latest requests, got %f", n)
+	}
+	if n := testutil.ToFloat64(ProviderErrors.WithLabelValues("fake", "latest")); n != 1 {
+		t.Fatalf("expected 1 latest error, got %f", n)
+	}
+	if n := testutil.ToFloat64(ProviderRequests.WithLabelValues("fake", "range")); n != 1 {
+		t.Fatalf("expected 1 range request, got %f", n)
+	}
+	if _, ok := p.(provider.Streamer); ok {
+		t.Fatal("expected a polling provider not to become a streamer")
+	}
+	if _, ok := Instrument(&fakeStreamer{}).(provider.Streamer); !ok {
+		t.Fatal("expected a streaming provider to keep streaming")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	LockWait.Observe(0.001)
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), "candles_store_lock_wait_seconds_count") {
+		t.Fatalf("expected lock wait metrics, got:\n%s", recorder.Body.String())
+	}
+}
@@ -1,41 +1,111 @@
 package polygon
 
 import (
+	"candles-api/clock"
 	"candles-api/data"
+	"candles-api/provider"
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/charmbracelet/log"
 	"github.com/go-resty/resty/v2"
+	"net/http"
 	"time"
 )
 
+const Name = "polygon"
+
+type timespan struct {
+	multiplier uint64
+	unit       string
+}
+
+var intervals = map[uint64]timespan{
+	60:    {1, "minute"},
+	300:   {5, "minute"},
+	900:   {15, "minute"},
+	1800:  {30, "minute"},
+	3600:  {1, "hour"},
+	14400: {4, "hour"},
+	86400: {1, "day"},
+}
+
 type Client struct {
-	host   string
-	apiKey string
+	baseUrl string
+	apiKey  string
+	client  *resty.Client
+	clock   clock.Clock
 }
 
 func NewClient(host string, apiKey string) *Client {
-	return &Client{host: host, apiKey: apiKey}
+	return &Client{baseUrl: "https://" + host, apiKey: apiKey, client: resty.New(), clock: clock.System()}
+}
+
+func (c *Client) WithBaseUrl(baseUrl string) *Client {
+	c.baseUrl = baseUrl
+	return c
+}
+
+func (c *Client) WithHttpClient(httpClient *http.Client) *Client {
+	c.client = resty.NewWithClient(httpClient)
+	return c
+}
+
+func (c *Client) WithClock(clock clock.Clock) *Client {
+	c.clock = clock
+	return c
+}
+
+func (c *Client) Name() string {
+	return Name
 }
 
-func (c *Client) GetLatestCandles(symbol string) []*data.Candle {
-	client := resty.New()
-	to := time.Now().Format(time.DateOnly)
-	from := time.Now().Add(time.Hour * -24 * 7).Format(time.DateOnly)
+func (c *Client) Intervals() []uint64 {
+	return []uint64{60, 300, 900, 1800, 3600, 14400, 86400}
+}
+
+func (c *Client) PollInterval() time.Duration {
+	return time.Second
+}
+
+func (c *Client) GetLatestCandles(ctx context.Context, symbol string, _ string) ([]*data.Candle, error) {
+	now := c.clock.Now()
+	to := now.Format(time.DateOnly)
+	from := now.Add(time.Hour * -24 * 7).Format(time.DateOnly)
 	url := fmt.Sprintf(
-		"https://%s/v2/aggs/ticker/C:%s/range/1/minute/%s/%s?adjusted=true&sort=asc&apiKey=%s&limit=50000",
-		c.host, symbol, from, to, c.apiKey,
+		"%s/v2/aggs/ticker/C:%s/range/1/minute/%s/%s?adjusted=true&sort=asc&apiKey=%s&limit=50000",
+		c.baseUrl, symbol, from, to, c.apiKey,
 	)
-	resp, err := client.R().Get(url)
+	return c.getCandles(ctx, url, symbol, 60)
+}
+
+func (c *Client) GetCandles(ctx context.Context, symbol string, _ string, interval uint64, from time.Time, to time.Time) ([]*data.Candle, error) {
+	span, ok := intervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("polygon does not support interval %d", interval)
+	}
+	return provider.Page(ctx, from, to, interval, 50000, time.Millisecond*100, func(from time.Time, to time.Time) ([]*data.Candle, error) {
+		url := fmt.Sprintf(
+			"%s/v2/aggs/ticker/C:%s/range/%d/%s/%d/%d?adjusted=true&sort=asc&apiKey=%s&limit=50000",
+			c.baseUrl, symbol, span.multiplier, span.unit, from.UnixMilli(), to.UnixMilli(), c.apiKey,
+		)
+		return c.getCandles(ctx, url, symbol, interval)
+	})
+}
+
+func (c *Client) getCandles(ctx context.Context, url string, symbol string, interval uint64) ([]*data.Candle, error) {
+	resp, err := c.client.R().SetContext(ctx).Get(url)
 	candles := make([]*data.Candle, 0)
 	if err != nil {
-		log.Errorf("cannot get candles from polygon %v", err)
-		return candles
+		return candles, fmt.Errorf("cannot get candles from polygon %v", err)
 	}
 	if resp.StatusCode() != 200 {
-		log.Errorf("cannot get candles from polygon %s", string(resp.Body()))
-		return candles
+		return candles, fmt.Errorf("cannot get candles from polygon %s %s", resp.Status(), string(resp.Body()))
 	}
+	return parseAggregates(resp.Body(), symbol, interval)
+}
+
+func parseAggregates(body []byte, symbol string, interval uint64) ([]*data.Candle, error) {
+	candles := make([]*data.Candle, 0)
 	res := struct {
 		Ticker  string `json:"ticker"`
 		Results []struct {
@@ -46,13 +116,12 @@ func (c *Client) GetLatestCandles(symbol string) []*data.Candle {
 			Timestamp int64   `json:"t"`
 		} `json:"results"`
 	}{}
-	err = json.Unmarshal(resp.Body(), &res)
+	err := json.Unmarshal(body, &res)
 	if err != nil {
-		log.Errorf("cannot get candles from polygon %v", err)
-		return candles
+		return candles, fmt.Errorf("cannot get candles from polygon %v", err)
 	}
 	for _, item := range res.Results {
-		closingTimestamp := item.Timestamp
+		openingTimestamp := item.Timestamp
 		openPrice := item.Open
 		highPrice := item.High
 		lowPrice := item.Low
@@ -62,9 +131,9 @@ func (c *Client) GetLatestCandles(symbol string) []*data.Candle {
 		candles = append(candles, data.NewCandle(
 			symbol,
 			"",
-			60,
-			uint64(closingTimestamp),
-			uint64(closingTimestamp-60000),
+			interval,
+			uint64(openingTimestamp)+interval*1000,
+			uint64(openingTimestamp),
 			openPrice,
 			closePrice,
 			highPrice,
@@ -73,5 +142,5 @@ func (c *Client) GetLatestCandles(symbol string) []*data.Candle {
 			turnover,
 		))
 	}
-	return candles
+	return candles, nil
 }
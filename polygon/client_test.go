@@ -1,13 +1,103 @@
 package polygon
 
 import (
-	"os"
+	"candles-api/data"
+	"candles-api/testutil"
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
-var ApiKey = os.Getenv("POLYGON_API_KEY")
+func TestClient_GetCandles(t *testing.T) {
+	from := time.UnixMilli(1704067200000)
+	to := time.UnixMilli(1704067260000)
+	tests := []struct {
+		name      string
+		responses []testutil.Response
+		expected  []*data.Candle
+		err       string
+	}{
+		{
+			name:      "aggregates",
+			responses: []testutil.Response{testutil.OK(t, "polygon/aggregates.json")},
+			expected: []*data.Candle{
+				data.NewCandle("EUR-USD", "", 60, 1704067260000, 1704067200000, 1.08341, 1.08345, 1.08349, 1.0834, 0, 0),
+				data.NewCandle("EUR-USD", "", 60, 1704067320000, 1704067260000, 1.08345, 1.0835, 1.08352, 1.08343, 0, 0),
+			},
+		},
+		{
+			name:      "empty results",
+			responses: []testutil.Response{testutil.OK(t, "polygon/empty.json")},
+			expected:  []*data.Candle{},
+		},
+		{
+			name:      "unknown api key",
+			responses: []testutil.Response{testutil.Status(t, http.StatusUnauthorized, "polygon/error.json")},
+			err:       "401 Unauthorized",
+		},
+		{
+			name:      "rate limited",
+			responses: []testutil.Response{testutil.Status(t, http.StatusTooManyRequests, "polygon/rate_limit.json")},
+			err:       "429 Too Many Requests",
+		},
+		{
+			name:      "malformed json",
+			responses: []testutil.Response{testutil.OK(t, "malformed.json")},
+			err:       "unexpected end of JSON input",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := testutil.NewServer(t, test.responses...)
+			client := NewClient("", "key").WithBaseUrl(server.URL).WithHttpClient(server.Client())
+			candles, err := client.GetCandles(context.Background(), "EUR-USD", "", 60, from, to)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(candles, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, candles)
+			}
+			requests := server.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("expected 1 request, got %v", requests)
+			}
+			if requests[0].Path != "/v2/aggs/ticker/C:EUR-USD/range/1/minute/1704067200000/1704067260000" ||
+				requests[0].Query().Get("apiKey") != "key" {
+				t.Fatalf("unexpected requests %v", requests)
+			}
+		})
+	}
+}
+
+func TestParseAggregates(t *testing.T) {
+	candles, err := parseAggregates([]byte(testutil.Payload(t, "polygon/aggregates.json")), "EUR-USD", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles, got %d", len(candles))
+	}
+	first := candles[0]
+	if first.OpeningTimestamp != 1704067200000 || first.ClosingTimestamp != 1704067260000 {
+		t.Fatalf("expected t as the opening timestamp, got %+v", first)
+	}
+	if first.Id != "EUR-USD_1704067260000_60" || first.Open != 1.08341 || first.High != 1.08349 ||
+		first.Low != 1.0834 || first.Close != 1.08345 || first.Volume != 0 {
+		t.Fatalf("unexpected candle %+v", first)
+	}
+}
 
-func TestClient_GetLatestCandles(t *testing.T) {
-	client := NewClient("api.polygon.io", ApiKey)
-	client.GetLatestCandles("EUR-USD")
+func TestParseAggregates_Malformed(t *testing.T) {
+	if _, err := parseAggregates([]byte(`{"results": [`), "EUR-USD", 60); err == nil {
+		t.Fatal("expected error")
+	}
 }
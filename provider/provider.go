@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"candles-api/data"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Provider interface {
+	Name() string
+	Intervals() []uint64
+	PollInterval() time.Duration
+	GetLatestCandles(ctx context.Context, symbol string, micCode string) ([]*data.Candle, error)
+	GetCandles(ctx context.Context, symbol string, micCode string, interval uint64, from time.Time, to time.Time) ([]*data.Candle, error)
+}
+
+type Registry struct {
+	providers map[string]Provider
+	lock      sync.RWMutex
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{
+		providers: map[string]Provider{},
+	}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+func (r *Registry) Register(p Provider) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.providers[p.Name()] = p
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) All() []Provider {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	providers := make([]Provider, 0, len(r.providers))
+	for _, p := range r.providers {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Name() < providers[j].Name()
+	})
+	return providers
+}
+
+func Supports(p Provider, interval uint64) bool {
+	for _, i := range p.Intervals() {
+		if i == interval {
+			return true
+		}
+	}
+	return false
+}
+
+func Page(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+	interval uint64,
+	pageSize uint64,
+	delay time.Duration,
+	fetch func(from time.Time, to time.Time) ([]*data.Candle, error),
+) ([]*data.Candle, error) {
+	candles := make([]*data.Candle, 0)
+	window := time.Duration(interval*pageSize) * time.Second
+	for start := from; start.Before(to); start = start.Add(window) {
+		if start != from && delay > 0 {
+			select {
+			case <-ctx.Done():
+				return candles, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return candles, err
+		}
+		end := start.Add(window - time.Millisecond)
+		if end.After(to) {
+			end = to
+		}
+		page, err := fetch(start, end)
+		if err != nil {
+			return candles, err
+		}
+		candles = append(candles, page...)
+	}
+	return candles, nil
+}
+
+type Streamer interface {
+	Stream(ctx context.Context, symbol string, onCandle func(*data.Candle), onConnect func()) error
+}
+
+type polled struct {
+	Provider
+}
+
+// Polled hides the Streamer of p, so its candles are fetched over its rest
+// api like those of any other provider.
+func Polled(p Provider) Provider {
+	return polled{p}
+}
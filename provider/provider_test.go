@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"candles-api/data"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPage(t *testing.T) {
+	from := time.UnixMilli(0)
+	to := time.UnixMilli(25 * 60000)
+	windows := make([][2]int64, 0)
+	candles, err := Page(context.Background(), from, to, 60, 10, 0, func(from time.Time, to time.Time) ([]*data.Candle, error) {
+		windows = append(windows, [2]int64{from.UnixMilli(), to.UnixMilli()})
+		return []*data.Candle{{ClosingTimestamp: uint64(from.UnixMilli())}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]int64{
+		{0, 10*60000 - 1},
+		{10 * 60000, 20*60000 - 1},
+		{20 * 60000, 25 * 60000},
+	}
+	if len(windows) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, windows)
+	}
+	for i := range expected {
+		if windows[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, windows)
+		}
+	}
+	if len(candles) != 3 {
+		t.Fatalf("expected 3 candles, got %d", len(candles))
+	}
+}
+
+func TestPage_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := 0
+	_, err := Page(ctx, time.UnixMilli(0), time.UnixMilli(25*60000), 60, 10, time.Hour, func(from time.Time, to time.Time) ([]*data.Candle, error) {
+		pages++
+		cancel()
+		return nil, nil
+	})
+	if !errors.Is(err, context.Canceled) || pages != 1 {
+		t.Fatalf("expected paging to stop after the first page, got %d pages and %v", pages, err)
+	}
+}
@@ -0,0 +1,247 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"candles-api/clock"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Extension = ".jsonl"
+
+// Exchange is one recorded request and the raw response it got. Credentials
+// are removed from Url, which doubles as the key responses are replayed by.
+type Exchange struct {
+	Time     int64       `json:"time"`
+	Duration int64       `json:"duration"`
+	Method   string      `json:"method"`
+	Host     string      `json:"host"`
+	Url      string      `json:"url"`
+	Status   int         `json:"status,omitempty"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     string      `json:"body,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// requestUrl returns the path and query of u without credentials, with the
+// query sorted so equal requests always give the same url.
+func requestUrl(u *url.URL) string {
+	query := u.Query()
+	for param := range query {
+		if strings.EqualFold(param, "apikey") {
+			query.Del(param)
+		}
+	}
+	if len(query) == 0 {
+		return u.Path
+	}
+	return u.Path + "?" + query.Encode()
+}
+
+func recording(dir string, name string) string {
+	return filepath.Join(dir, name+Extension)
+}
+
+// Recorder is a RoundTripper that appends every exchange of a provider to
+// <dir>/<name>.jsonl before handing the response on.
+type Recorder struct {
+	next  http.RoundTripper
+	clock clock.Clock
+	file  *os.File
+	lock  sync.Mutex
+}
+
+func NewRecorder(dir string, name string, next http.RoundTripper, clock clock.Clock) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create recording directory %v", err)
+	}
+	file, err := os.OpenFile(recording(dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open recording %v", err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next, clock: clock, file: file}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := r.clock.Now()
+	resp, err := r.next.RoundTrip(req)
+	exchange := &Exchange{
+		Time:     started.UnixMilli(),
+		Duration: r.clock.Now().Sub(started).Milliseconds(),
+		Method:   req.Method,
+		Host:     req.URL.Host,
+		Url:      requestUrl(req.URL),
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+		r.write(exchange)
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		exchange.Error = err.Error()
+		r.write(exchange)
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	exchange.Status = resp.StatusCode
+	exchange.Header = resp.Header
+	exchange.Body = string(body)
+	r.write(exchange)
+	return resp, nil
+}
+
+func (r *Recorder) write(exchange *Exchange) {
+	appendLine(r.file, &r.lock, exchange)
+}
+
+func appendLine(file *os.File, lock *sync.Mutex, value any) {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	_, _ = file.Write(append(line, '\n'))
+}
+
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.file.Close()
+}
+
+func read(file string) ([]*Exchange, error) {
+	return readLines[Exchange](file)
+}
+
+func readLines[T any](file string) ([]*T, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open recording %w", err)
+	}
+	defer f.Close()
+	values := make([]*T, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		value := new(T)
+		if err := json.Unmarshal(scanner.Bytes(), value); err != nil {
+			return nil, fmt.Errorf("cannot parse line %d of %s %v", line, file, err)
+		}
+		values = append(values, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read recording %v", err)
+	}
+	return values, nil
+}
+
+// Start returns the time of the earliest exchange or stream frame recorded
+// in dir, where a replay of it begins. Both are read by their time alone.
+func Start(dir string) (time.Time, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+Extension))
+	if err != nil {
+		return time.Time{}, err
+	}
+	earliest := int64(0)
+	for _, file := range files {
+		exchanges, err := read(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, exchange := range exchanges {
+			if earliest == 0 || exchange.Time < earliest {
+				earliest = exchange.Time
+			}
+		}
+	}
+	if earliest == 0 {
+		return time.Time{}, fmt.Errorf("no recorded exchanges in %s", dir)
+	}
+	return time.UnixMilli(earliest), nil
+}
+
+// Replayer is a RoundTripper that answers requests from a recording instead
+// of the network. A request gets the last response recorded for it at or
+// before the time of the clock, so running it on a clock that starts where
+// the recording did serves the responses on their original timeline.
+type Replayer struct {
+	exchanges map[string][]*Exchange
+	clock     clock.Clock
+}
+
+func Load(dir string, name string, clock clock.Clock) (*Replayer, error) {
+	r := &Replayer{exchanges: map[string][]*Exchange{}, clock: clock}
+	exchanges, err := read(recording(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, exchange := range exchanges {
+		key := exchange.Method + " " + exchange.Url
+		r.exchanges[key] = append(r.exchanges[key], exchange)
+	}
+	for _, recorded := range r.exchanges {
+		slices.SortStableFunc(recorded, func(a *Exchange, b *Exchange) int {
+			return cmp.Compare(a.Time, b.Time)
+		})
+	}
+	return r, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	key := req.Method + " " + requestUrl(req.URL)
+	now := r.clock.Now().UnixMilli()
+	recorded := r.exchanges[key]
+	i, _ := slices.BinarySearchFunc(recorded, now+1, func(exchange *Exchange, t int64) int {
+		return cmp.Compare(exchange.Time, t)
+	})
+	if i == 0 {
+		return nil, fmt.Errorf("no response recorded for %s at %d", key, now)
+	}
+	exchange := recorded[i-1]
+	if len(exchange.Error) > 0 {
+		return nil, errors.New(exchange.Error)
+	}
+	header := exchange.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+		StatusCode:    exchange.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(exchange.Body)),
+		ContentLength: int64(len(exchange.Body)),
+		Request:       req,
+	}, nil
+}
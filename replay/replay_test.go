@@ -0,0 +1,69 @@
+package replay
+
+import (
+	"candles-api/clock"
+	"candles-api/testutil"
+	"candles-api/twelve_data"
+	"context"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	m := clock.NewManual(start)
+	server := testutil.NewServer(t,
+		testutil.OK(t, "twelve_data/time_series.json"),
+		testutil.OK(t, "twelve_data/rate_limit.json"),
+	)
+	recorder, err := NewRecorder(dir, twelve_data.Name, server.Client().Transport, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := twelve_data.NewClient("", "secret").WithBaseUrl(server.URL).WithHttpClient(&http.Client{Transport: recorder})
+	recorded, err := client.GetLatestCandles(context.Background(), "XAU/USD", "COMMODITY")
+	if err != nil || len(recorded) != 2 {
+		t.Fatalf("expected the recorded candles, got %v %v", recorded, err)
+	}
+	m.Advance(time.Minute)
+	if _, err := client.GetLatestCandles(context.Background(), "XAU/USD", "COMMODITY"); err == nil {
+		t.Fatal("expected the rate limit to be passed on while recording")
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(recording(dir, twelve_data.Name))
+	if strings.Contains(string(raw), "secret") {
+		t.Fatal("expected credentials to be left out of the recording")
+	}
+
+	if started, err := Start(dir); err != nil || !started.Equal(start) {
+		t.Fatalf("expected the replay to start at %s, got %s %v", start, started, err)
+	}
+	replayClock := clock.NewManual(start.Add(-time.Second))
+	replayer, err := Load(dir, twelve_data.Name, replayClock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaying := twelve_data.NewClient("api.twelvedata.com", "other").WithHttpClient(&http.Client{Transport: replayer})
+	if _, err := replaying.GetLatestCandles(context.Background(), "XAU/USD", "COMMODITY"); err == nil || !strings.Contains(err.Error(), "no response recorded") {
+		t.Fatalf("expected nothing to be served before the recording starts, got %v", err)
+	}
+	replayClock.Advance(time.Second * 30)
+	replayed, err := replaying.GetLatestCandles(context.Background(), "XAU/USD", "COMMODITY")
+	if err != nil || !reflect.DeepEqual(replayed, recorded) {
+		t.Fatalf("expected %+v, got %+v %v", recorded, replayed, err)
+	}
+	replayClock.Advance(time.Minute)
+	if _, err := replaying.GetLatestCandles(context.Background(), "XAU/USD", "COMMODITY"); err == nil || !strings.Contains(err.Error(), "run out of API credits") {
+		t.Fatalf("expected the recorded rate limit on its original timeline, got %v", err)
+	}
+	if _, err := replaying.GetLatestCandles(context.Background(), "WTI/USD", "COMMODITY"); err == nil {
+		t.Fatal("expected unrecorded requests to fail")
+	}
+}
@@ -0,0 +1,122 @@
+package replay
+
+import (
+	"candles-api/clock"
+	"candles-api/data"
+	"candles-api/provider"
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FrameInterval is how often a replayed stream delivers the frames that have
+// come due on its clock.
+const FrameInterval = time.Millisecond * 100
+
+// Frame is one event a provider pushed over its stream for a symbol, either
+// a (re)connect or a candle.
+type Frame struct {
+	Time    int64        `json:"time"`
+	Symbol  string       `json:"symbol"`
+	Connect bool         `json:"connect,omitempty"`
+	Candle  *data.Candle `json:"candle,omitempty"`
+}
+
+func streamRecording(dir string, name string) string {
+	return recording(dir, name+".stream")
+}
+
+// StreamRecorder wraps a streaming provider and appends every connect and
+// candle it streams to <dir>/<name>.stream.jsonl before handing it on.
+type StreamRecorder struct {
+	provider.Provider
+	streamer provider.Streamer
+	clock    clock.Clock
+	file     *os.File
+	lock     sync.Mutex
+}
+
+func NewStreamRecorder(dir string, p provider.Provider, clock clock.Clock) (*StreamRecorder, error) {
+	streamer, ok := p.(provider.Streamer)
+	if !ok {
+		return nil, fmt.Errorf("%s does not stream", p.Name())
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create recording directory %v", err)
+	}
+	file, err := os.OpenFile(streamRecording(dir, p.Name()), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open stream recording %v", err)
+	}
+	return &StreamRecorder{Provider: p, streamer: streamer, clock: clock, file: file}, nil
+}
+
+func (r *StreamRecorder) Stream(ctx context.Context, symbol string, onCandle func(*data.Candle), onConnect func()) error {
+	return r.streamer.Stream(ctx, symbol, func(candle *data.Candle) {
+		copied := *candle
+		appendLine(r.file, &r.lock, &Frame{Time: r.clock.Now().UnixMilli(), Symbol: symbol, Candle: &copied})
+		onCandle(candle)
+	}, func() {
+		appendLine(r.file, &r.lock, &Frame{Time: r.clock.Now().UnixMilli(), Symbol: symbol, Connect: true})
+		onConnect()
+	})
+}
+
+func (r *StreamRecorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.file.Close()
+}
+
+// StreamReplayer serves the stream of a provider from a recording, every
+// other call goes to the provider. A stream delivers the frames recorded for
+// its symbol once the clock reaches their time, and stays open when they run
+// out, like a market that has gone quiet.
+type StreamReplayer struct {
+	provider.Provider
+	frames map[string][]*Frame
+	clock  clock.Clock
+}
+
+// LoadStream returns an error wrapping os.ErrNotExist when nothing was
+// recorded for the stream of p.
+func LoadStream(dir string, p provider.Provider, clock clock.Clock) (*StreamReplayer, error) {
+	frames, err := readLines[Frame](streamRecording(dir, p.Name()))
+	if err != nil {
+		return nil, err
+	}
+	r := &StreamReplayer{Provider: p, frames: map[string][]*Frame{}, clock: clock}
+	for _, frame := range frames {
+		r.frames[frame.Symbol] = append(r.frames[frame.Symbol], frame)
+	}
+	for _, recorded := range r.frames {
+		slices.SortStableFunc(recorded, func(a *Frame, b *Frame) int {
+			return cmp.Compare(a.Time, b.Time)
+		})
+	}
+	return r, nil
+}
+
+func (r *StreamReplayer) Stream(ctx context.Context, symbol string, onCandle func(*data.Candle), onConnect func()) error {
+	frames := r.frames[symbol]
+	next := 0
+	deliver := func(ctx context.Context) {
+		now := r.clock.Now().UnixMilli()
+		for ; next < len(frames) && frames[next].Time <= now && ctx.Err() == nil; next++ {
+			frame := frames[next]
+			if frame.Connect {
+				onConnect()
+			} else if frame.Candle != nil {
+				copied := *frame.Candle
+				onCandle(&copied)
+			}
+		}
+	}
+	deliver(ctx)
+	r.clock.Every(ctx, FrameInterval, deliver)
+	return ctx.Err()
+}
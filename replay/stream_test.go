@@ -0,0 +1,119 @@
+package replay
+
+import (
+	"candles-api/clock"
+	"candles-api/data"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type scriptedStreamer struct {
+	clock  *clock.Manual
+	script []func(onCandle func(*data.Candle), onConnect func())
+}
+
+func (p *scriptedStreamer) Name() string {
+	return "scripted"
+}
+
+func (p *scriptedStreamer) Intervals() []uint64 {
+	return []uint64{60}
+}
+
+func (p *scriptedStreamer) PollInterval() time.Duration {
+	return time.Second
+}
+
+func (p *scriptedStreamer) GetLatestCandles(context.Context, string, string) ([]*data.Candle, error) {
+	return nil, nil
+}
+
+func (p *scriptedStreamer) GetCandles(context.Context, string, string, uint64, time.Time, time.Time) ([]*data.Candle, error) {
+	return nil, nil
+}
+
+func (p *scriptedStreamer) Stream(_ context.Context, _ string, onCandle func(*data.Candle), onConnect func()) error {
+	for _, step := range p.script {
+		step(onCandle, onConnect)
+		p.clock.Advance(time.Second * 10)
+	}
+	return nil
+}
+
+func TestRecordAndReplayStream(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	m := clock.NewManual(start)
+	first := data.NewCandle("BTCUSDT", "", 60, 60000, 0, 1, 2, 2, 1, 1, 1)
+	second := data.NewCandle("BTCUSDT", "", 60, 60000, 0, 1, 3, 3, 1, 2, 2)
+	streamer := &scriptedStreamer{clock: m, script: []func(func(*data.Candle), func()){
+		func(_ func(*data.Candle), onConnect func()) { onConnect() },
+		func(onCandle func(*data.Candle), _ func()) { onCandle(first) },
+		func(onCandle func(*data.Candle), _ func()) { onCandle(second) },
+	}}
+	if _, err := LoadStream(dir, streamer, m); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a missing stream recording to be reported, got %v", err)
+	}
+	recorder, err := NewStreamRecorder(dir, streamer, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := 0
+	if err = recorder.Stream(context.Background(), "BTCUSDT", func(*data.Candle) { recorded++ }, func() {}); err != nil || recorded != 2 {
+		t.Fatalf("expected the candles to be passed on while recording, got %d %v", recorded, err)
+	}
+	if err = recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if started, err := Start(dir); err != nil || !started.Equal(start) {
+		t.Fatalf("expected the replay to start at %s, got %s %v", start, started, err)
+	}
+
+	replayClock := clock.NewManual(start)
+	replayer, err := LoadStream(dir, streamer, replayClock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock sync.Mutex
+	events := make([]string, 0)
+	closes := make([]float64, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- replayer.Stream(ctx, "BTCUSDT", func(candle *data.Candle) {
+			lock.Lock()
+			defer lock.Unlock()
+			events = append(events, "candle")
+			closes = append(closes, candle.Close)
+		}, func() {
+			lock.Lock()
+			defer lock.Unlock()
+			events = append(events, "connect")
+		})
+	}()
+	replayClock.BlockUntil(1)
+	observed := func() ([]string, []float64) {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, events...), append([]float64{}, closes...)
+	}
+	if got, _ := observed(); len(got) != 1 || got[0] != "connect" {
+		t.Fatalf("expected only the connect at the start, got %v", got)
+	}
+	replayClock.Advance(time.Second * 10)
+	if got, _ := observed(); len(got) != 2 {
+		t.Fatalf("expected the first candle after 10s, got %v", got)
+	}
+	replayClock.Advance(time.Second * 10)
+	if got, closes := observed(); len(got) != 3 || closes[0] != 2 || closes[1] != 3 {
+		t.Fatalf("expected both candles on their original timeline, got %v %v", got, closes)
+	}
+	cancel()
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the replayed stream to stay open until cancelled, got %v", err)
+	}
+}
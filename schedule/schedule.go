@@ -0,0 +1,149 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	Always = "24/7"
+	Forex  = "24/5"
+)
+
+var weekdays = map[string]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+type session struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// Schedule describes when a market is expected to produce 1m candles. It is
+// parsed from either "24/7", "24/5" (forex, Sunday 17:00 to Friday 17:00 New
+// York time) or "<days> <sessions> <time zone>", e.g.
+// "Mon-Fri 09:00-11:30,12:30-15:00 Asia/Tokyo". A session whose end is before
+// its start runs past midnight into the next day. Sessions that trade on
+// different days are joined with "|", e.g.
+// "Sun-Thu 19:00-07:45 America/Chicago | Mon-Fri 08:30-13:20 America/Chicago".
+type Schedule struct {
+	days     map[time.Weekday]bool
+	sessions []session
+	location *time.Location
+	always   bool
+	any      []*Schedule
+}
+
+func Parse(spec string) (*Schedule, error) {
+	switch spec {
+	case "", Always:
+		return &Schedule{always: true}, nil
+	case Forex:
+		return Parse("Sun-Thu 17:00-17:00 America/New_York")
+	}
+	if parts := strings.Split(spec, "|"); len(parts) > 1 {
+		union := &Schedule{}
+		for _, part := range parts {
+			parsed, err := Parse(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			union.any = append(union.any, parsed)
+		}
+		return union, nil
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("schedule %q must be %s, %s or \"<days> <HH:MM-HH:MM,...> <time zone>\"", spec, Always, Forex)
+	}
+	days, err := parseDays(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q: %v", spec, err)
+	}
+	sessions := make([]session, 0)
+	for _, part := range strings.Split(fields[1], ",") {
+		startStr, endStr, ok := strings.Cut(part, "-")
+		start, err1 := parseTimeOfDay(startStr)
+		end, err2 := parseTimeOfDay(endStr)
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("schedule %q: session %q must be HH:MM-HH:MM", spec, part)
+		}
+		sessions = append(sessions, session{start: start, end: end})
+	}
+	location, err := time.LoadLocation(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q: %v", spec, err)
+	}
+	return &Schedule{days: days, sessions: sessions, location: location}, nil
+}
+
+func (s *Schedule) IsOpen(t time.Time) bool {
+	if s.always {
+		return true
+	}
+	if s.any != nil {
+		for _, part := range s.any {
+			if part.IsOpen(t) {
+				return true
+			}
+		}
+		return false
+	}
+	local := t.In(s.location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+	timeOfDay := local.Sub(midnight)
+	today := local.Weekday()
+	yesterday := (today + 6) % 7
+	for _, session := range s.sessions {
+		if session.start < session.end {
+			if s.days[today] && timeOfDay >= session.start && timeOfDay < session.end {
+				return true
+			}
+			continue
+		}
+		if s.days[today] && timeOfDay >= session.start {
+			return true
+		}
+		if s.days[yesterday] && timeOfDay < session.end {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDays(value string) (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	for _, part := range strings.Split(value, ",") {
+		firstStr, lastStr, isRange := strings.Cut(part, "-")
+		first, ok1 := weekdays[firstStr]
+		last, ok2 := first, true
+		if isRange {
+			last, ok2 = weekdays[lastStr]
+		}
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("days %q must be like Mon-Fri or Sun,Tue", value)
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
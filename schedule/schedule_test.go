@@ -0,0 +1,72 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+const wheat = "Sun-Thu 19:00-07:45 America/Chicago | Mon-Fri 08:30-13:20 America/Chicago"
+
+func TestSchedule_IsOpen(t *testing.T) {
+	utc := func(value string) time.Time {
+		ts, err := time.Parse(time.DateTime, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	cases := []struct {
+		spec     string
+		at       string
+		expected bool
+	}{
+		{spec: Always, at: "2024-01-06 12:00:00", expected: true},
+		// forex opens Sunday 17:00 New York (22:00 UTC in winter) and closes Friday 17:00
+		{spec: Forex, at: "2024-01-07 21:59:00", expected: false},
+		{spec: Forex, at: "2024-01-07 22:00:00", expected: true},
+		{spec: Forex, at: "2024-01-10 12:00:00", expected: true},
+		{spec: Forex, at: "2024-01-12 21:59:00", expected: true},
+		{spec: Forex, at: "2024-01-12 22:00:00", expected: false},
+		{spec: Forex, at: "2024-01-13 12:00:00", expected: false},
+		{spec: "Mon-Fri 08:00-16:30 Europe/London", at: "2024-01-08 07:59:00", expected: false},
+		{spec: "Mon-Fri 08:00-16:30 Europe/London", at: "2024-01-08 08:00:00", expected: true},
+		{spec: "Mon-Fri 08:00-16:30 Europe/London", at: "2024-01-08 16:30:00", expected: false},
+		{spec: "Mon-Fri 08:00-16:30 Europe/London", at: "2024-01-06 12:00:00", expected: false},
+		// Tokyo is UTC+9, the lunch break runs 11:30-12:30 local
+		{spec: "Mon-Fri 09:00-11:30,12:30-15:00 Asia/Tokyo", at: "2024-01-09 02:00:00", expected: true},
+		{spec: "Mon-Fri 09:00-11:30,12:30-15:00 Asia/Tokyo", at: "2024-01-09 03:00:00", expected: false},
+		{spec: "Mon-Fri 09:00-11:30,12:30-15:00 Asia/Tokyo", at: "2024-01-09 04:00:00", expected: true},
+		// CBOT wheat trades Sunday to Thursday evenings from 19:00 Chicago (UTC-6 in
+		// winter) and again on weekdays after the 07:45-08:30 break
+		{spec: wheat, at: "2024-01-07 01:30:00", expected: false},
+		{spec: wheat, at: "2024-01-08 01:30:00", expected: true},
+		{spec: wheat, at: "2024-01-08 14:00:00", expected: false},
+		{spec: wheat, at: "2024-01-08 14:45:00", expected: true},
+		{spec: wheat, at: "2024-01-08 19:30:00", expected: false},
+		{spec: wheat, at: "2024-01-12 14:45:00", expected: true},
+		{spec: wheat, at: "2024-01-13 01:30:00", expected: false},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.IsOpen(utc(c.at)); got != c.expected {
+			t.Errorf("%s at %s: expected %v, got %v", c.spec, c.at, c.expected, got)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"weekdays",
+		"Mon-Fri 08:00 Europe/London",
+		"Mon-Fxi 08:00-16:30 Europe/London",
+		"Mon-Fri 08:00-16:30 Europe/Nowhere",
+		"Mon-Fri 08:00-16:30 Europe/London | weekends",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"candles-api/data"
+	"fmt"
+	"sync"
+)
+
+const (
+	SeriesBackend = "series"
+	MemDbBackend  = "memdb"
+)
+
+// Backend holds the candles of a Store. Candles passed to Save are owned by
+// the backend afterwards and the candles it returns must not be modified.
+// Writes are serialised by the Store, reads may happen concurrently.
+type Backend interface {
+	Save(candle *data.Candle) bool
+	Remove(marketId string, interval uint64, closingTimestamp uint64) bool
+	Range(marketId string, interval uint64, fromTimestamp uint64, toTimestamp uint64) []*data.Candle
+	Latest(marketId string, interval uint64, n int) []*data.Candle
+	Earliest(marketId string, interval uint64, n int) []*data.Candle
+	Count(marketId string, interval uint64) int
+	TrimBefore(marketId string, interval uint64, closingTimestamp uint64) []*data.Candle
+	Purge(marketId string) []*data.Candle
+	All() []*data.Candle
+}
+
+func NewBackend(name string) (Backend, error) {
+	switch name {
+	case SeriesBackend:
+		return NewSeriesBackend(), nil
+	case MemDbBackend:
+		return NewMemDbBackend()
+	default:
+		return nil, fmt.Errorf("unknown store backend %q, expected %s or %s", name, SeriesBackend, MemDbBackend)
+	}
+}
+
+type seriesBackend struct {
+	candles map[string]map[uint64]*series
+	lock    sync.RWMutex
+}
+
+func NewSeriesBackend() Backend {
+	return &seriesBackend{
+		candles: map[string]map[uint64]*series{},
+	}
+}
+
+func (b *seriesBackend) Save(candle *data.Candle) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.candles[candle.MarketId] == nil {
+		b.candles[candle.MarketId] = map[uint64]*series{}
+	}
+	if b.candles[candle.MarketId][candle.Interval] == nil {
+		b.candles[candle.MarketId][candle.Interval] = &series{}
+	}
+	return b.candles[candle.MarketId][candle.Interval].upsert(candle)
+}
+
+func (b *seriesBackend) Remove(marketId string, interval uint64, closingTimestamp uint64) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.candles[marketId] == nil || b.candles[marketId][interval] == nil {
+		return false
+	}
+	return b.candles[marketId][interval].remove(closingTimestamp)
+}
+
+func (b *seriesBackend) Range(marketId string, interval uint64, fromTimestamp uint64, toTimestamp uint64) []*data.Candle {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.candles[marketId] == nil || b.candles[marketId][interval] == nil {
+		return nil
+	}
+	return append([]*data.Candle(nil), b.candles[marketId][interval].between(fromTimestamp, toTimestamp)...)
+}
+
+func (b *seriesBackend) Latest(marketId string, interval uint64, n int) []*data.Candle {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.candles[marketId] == nil || b.candles[marketId][interval] == nil {
+		return nil
+	}
+	candles := b.candles[marketId][interval].candles
+	return append([]*data.Candle(nil), candles[max(len(candles)-n, 0):]...)
+}
+
+func (b *seriesBackend) Earliest(marketId string, interval uint64, n int) []*data.Candle {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.candles[marketId] == nil || b.candles[marketId][interval] == nil {
+		return nil
+	}
+	candles := b.candles[marketId][interval].candles
+	return append([]*data.Candle(nil), candles[:min(n, len(candles))]...)
+}
+
+func (b *seriesBackend) Count(marketId string, interval uint64) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.candles[marketId] == nil || b.candles[marketId][interval] == nil {
+		return 0
+	}
+	return len(b.candles[marketId][interval].candles)
+}
+
+func (b *seriesBackend) TrimBefore(marketId string, interval uint64, closingTimestamp uint64) []*data.Candle {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.candles[marketId] == nil || b.candles[marketId][interval] == nil {
+		return nil
+	}
+	return b.candles[marketId][interval].trimBefore(closingTimestamp)
+}
+
+func (b *seriesBackend) Purge(marketId string) []*data.Candle {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	purged := make([]*data.Candle, 0)
+	for _, series := range b.candles[marketId] {
+		purged = append(purged, series.candles...)
+	}
+	delete(b.candles, marketId)
+	return purged
+}
+
+func (b *seriesBackend) All() []*data.Candle {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	candles := make([]*data.Candle, 0)
+	for _, intervals := range b.candles {
+		for _, series := range intervals {
+			candles = append(candles, series.candles...)
+		}
+	}
+	return candles
+}
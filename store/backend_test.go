@@ -0,0 +1,89 @@
+package store
+
+import (
+	"candles-api/data"
+	"slices"
+	"testing"
+)
+
+func forEachBackend(t *testing.T, test func(t *testing.T, b Backend)) {
+	for _, name := range []string{SeriesBackend, MemDbBackend} {
+		t.Run(name, func(t *testing.T) {
+			b, err := NewBackend(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, b)
+		})
+	}
+}
+
+func TestBackend_SaveAndRange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b Backend) {
+		for _, ts := range []uint64{180000, 60000, 120000, 240000} {
+			if !b.Save(candleAt(ts, 1)) {
+				t.Fatalf("expected candle %d to be saved", ts)
+			}
+		}
+		b.Save(data.NewCandle("BTCUSDT", "btc", 300, 300000, 0, 1, 1, 1, 1, 0, 0))
+		b.Save(data.NewCandle("BTCUSDT", "btc2", 60, 60000, 0, 1, 1, 1, 1, 0, 0))
+		if b.Save(candleAt(120000, 1)) {
+			t.Fatal("expected identical candle to be reported unchanged")
+		}
+		if !b.Save(candleAt(120000, 2)) {
+			t.Fatal("expected changed candle to be saved")
+		}
+		if got := timestamps(b.Range("btc", 60, 120000, 180000)); !slices.Equal(got, []uint64{120000, 180000}) {
+			t.Fatalf("unexpected range %v", got)
+		}
+		if got := timestamps(b.Range("btc", 60, 1, 0)); !slices.Equal(got, []uint64{60000, 120000, 180000, 240000}) {
+			t.Fatalf("unexpected open range %v", got)
+		}
+		if got := b.Range("btc", 60, 120000, 120000); len(got) != 1 || got[0].Close != 2 {
+			t.Fatalf("expected updated candle, got %+v", got)
+		}
+		if got := timestamps(b.Latest("btc", 60, 2)); !slices.Equal(got, []uint64{180000, 240000}) {
+			t.Fatalf("unexpected latest %v", got)
+		}
+		if got := timestamps(b.Earliest("btc", 60, 2)); !slices.Equal(got, []uint64{60000, 120000}) {
+			t.Fatalf("unexpected earliest %v", got)
+		}
+		if got := b.Earliest("eth", 60, 2); len(got) != 0 {
+			t.Fatalf("expected no candles for unknown market, got %v", got)
+		}
+		if got := b.Latest("eth", 60, 2); len(got) != 0 {
+			t.Fatalf("expected no candles for unknown market, got %v", got)
+		}
+		if b.Count("btc", 60) != 4 || b.Count("btc", 300) != 1 || b.Count("btc2", 60) != 1 || b.Count("eth", 60) != 0 {
+			t.Fatalf("unexpected counts %d %d %d", b.Count("btc", 60), b.Count("btc", 300), b.Count("btc2", 60))
+		}
+		if len(b.All()) != 6 {
+			t.Fatalf("expected 6 candles, got %d", len(b.All()))
+		}
+	})
+}
+
+func TestBackend_RemoveTrimAndPurge(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b Backend) {
+		for ts := uint64(60000); ts <= 300000; ts += 60000 {
+			b.Save(candleAt(ts, 1))
+		}
+		b.Save(data.NewCandle("BTCUSDT", "btc", 300, 300000, 0, 1, 1, 1, 1, 0, 0))
+		b.Save(data.NewCandle("BTCUSDT", "btc2", 60, 60000, 0, 1, 1, 1, 1, 0, 0))
+		if !b.Remove("btc", 60, 240000) || b.Remove("btc", 60, 240000) {
+			t.Fatal("expected remove to succeed exactly once")
+		}
+		if got := timestamps(b.TrimBefore("btc", 60, 150000)); !slices.Equal(got, []uint64{60000, 120000}) {
+			t.Fatalf("unexpected trimmed %v", got)
+		}
+		if got := timestamps(b.Range("btc", 60, 1, 0)); !slices.Equal(got, []uint64{180000, 300000}) {
+			t.Fatalf("unexpected remaining %v", got)
+		}
+		if purged := b.Purge("btc"); len(purged) != 3 {
+			t.Fatalf("expected 3 purged candles, got %d", len(purged))
+		}
+		if remaining := b.All(); len(remaining) != 1 || remaining[0].MarketId != "btc2" {
+			t.Fatalf("expected only btc2 to remain, got %+v", remaining)
+		}
+	})
+}
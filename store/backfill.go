@@ -0,0 +1,92 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"context"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"maps"
+	"slices"
+	"time"
+)
+
+func (s *Store) Backfill(ctx context.Context, marketId string, from time.Time, to time.Time) (int, error) {
+	var market *Config
+	for _, c := range s.Config() {
+		if c.MarketId == marketId {
+			market = c
+		}
+	}
+	if market == nil {
+		return 0, fmt.Errorf("market %s is not configured", marketId)
+	}
+	p, ok := s.providers.Get(string(market.PriceSource))
+	if !ok {
+		return 0, fmt.Errorf("price source %s is not registered", market.PriceSource)
+	}
+	saved := 0
+	for _, interval := range s.Intervals() {
+		if !provider.Supports(p, interval.Seconds) {
+			log.Infof("%s has no native %ds candles, they will be aggregated from 1m", p.Name(), interval.Seconds)
+			continue
+		}
+		start := from
+		if oldest := s.clock.Now().Add(-interval.Retention); start.Before(oldest) {
+			start = oldest
+		}
+		if !start.Before(to) {
+			log.Infof("skipping %ds candles, range is outside the %s retention", interval.Seconds, interval.Retention)
+			continue
+		}
+		log.Infof("backfilling %s %ds candles from %s to %s", market.Symbol, interval.Seconds, start.Format(time.DateTime), to.Format(time.DateTime))
+		if interval.Seconds == 60 && market.IsConsensus() {
+			n, err := s.backfillConsensus(ctx, market, start, to)
+			saved += n
+			if err != nil {
+				return saved, err
+			}
+			continue
+		}
+		candles, err := p.GetCandles(ctx, market.Symbol, market.MicCode, interval.Seconds, start, to)
+		s.ingest(market, market.AllSources()[0], candles)
+		saved += len(candles)
+		if err != nil {
+			return saved, fmt.Errorf("cannot backfill %ds candles for %s: %v", interval.Seconds, market.MarketId, err)
+		}
+	}
+	return saved, nil
+}
+
+func (s *Store) backfillConsensus(ctx context.Context, market *Config, from time.Time, to time.Time) (int, error) {
+	minutes := map[uint64]map[string]*data.Candle{}
+	for _, source := range market.AllSources() {
+		p, ok := s.providers.Get(string(source.PriceSource))
+		if !ok {
+			return 0, fmt.Errorf("price source %s is not registered", source.PriceSource)
+		}
+		candles, err := p.GetCandles(ctx, source.Symbol, source.MicCode, 60, from, to)
+		if err != nil {
+			return 0, fmt.Errorf("cannot backfill 60s candles for %s from %s: %v", market.MarketId, source.Key(), err)
+		}
+		for _, candle := range candles {
+			candle.MarketId = market.MarketId
+			if err := ValidateCandle(candle); err != nil {
+				s.quarantine(market.MarketId, source.Key(), candle, err.Error())
+				continue
+			}
+			if minutes[candle.ClosingTimestamp] == nil {
+				minutes[candle.ClosingTimestamp] = map[string]*data.Candle{}
+			}
+			minutes[candle.ClosingTimestamp][source.Key()] = candle
+		}
+	}
+	saved := 0
+	for _, ts := range slices.Sorted(maps.Keys(minutes)) {
+		if candle := Consensus(market, minutes[ts]); candle != nil {
+			s.accept(market, "consensus", candle)
+			saved++
+		}
+	}
+	return saved, nil
+}
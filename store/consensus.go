@@ -0,0 +1,189 @@
+package store
+
+import (
+	"candles-api/data"
+	"github.com/charmbracelet/log"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxDeviation = 1.0
+	ConsensusRetention  = time.Hour * 24
+)
+
+type consensusTracker struct {
+	staged map[string]map[uint64]map[string]*data.Candle
+	lock   sync.Mutex
+}
+
+func (s *Store) ingest(config *Config, source *Source, candles []*data.Candle) {
+	valid := make([]*data.Candle, 0, len(candles))
+	for _, candle := range candles {
+		candle.MarketId = config.MarketId
+		if err := ValidateCandle(candle); err != nil {
+			s.quarantine(config.MarketId, source.Key(), candle, err.Error())
+			continue
+		}
+		valid = append(valid, candle)
+	}
+	if !config.IsConsensus() {
+		for _, candle := range valid {
+			s.accept(config, source.Key(), candle)
+		}
+		return
+	}
+	for _, candle := range s.stageConsensus(config, source, valid) {
+		s.accept(config, "consensus", candle)
+	}
+}
+
+func (s *Store) stageConsensus(config *Config, source *Source, candles []*data.Candle) []*data.Candle {
+	s.consensus.lock.Lock()
+	defer s.consensus.lock.Unlock()
+	staged := s.consensus.staged[config.MarketId]
+	if staged == nil {
+		staged = map[uint64]map[string]*data.Candle{}
+		s.consensus.staged[config.MarketId] = staged
+	}
+	oldest := uint64(s.clock.Now().Add(-ConsensusRetention).UnixMilli())
+	for ts := range staged {
+		if ts < oldest {
+			delete(staged, ts)
+		}
+	}
+	touched := make([]uint64, 0)
+	for _, candle := range candles {
+		if candle.Interval != 60 || candle.ClosingTimestamp < oldest {
+			continue
+		}
+		candle.MarketId = config.MarketId
+		if staged[candle.ClosingTimestamp] == nil {
+			staged[candle.ClosingTimestamp] = map[string]*data.Candle{}
+		}
+		existing := staged[candle.ClosingTimestamp][source.Key()]
+		if existing != nil && existing.Equal(candle) {
+			continue
+		}
+		staged[candle.ClosingTimestamp][source.Key()] = candle
+		touched = append(touched, candle.ClosingTimestamp)
+	}
+	results := make([]*data.Candle, 0, len(touched))
+	for _, ts := range touched {
+		if candle := Consensus(config, staged[ts]); candle != nil {
+			results = append(results, candle)
+		}
+	}
+	return results
+}
+
+// Consensus builds a market candle from the candles reported by its sources
+// for the same minute. Sources whose close deviates from the weighted median
+// close by more than their MaxDeviation percent are left out, and nil is
+// returned when fewer than MinSources remain.
+func Consensus(config *Config, candles map[string]*data.Candle) *data.Candle {
+	type contribution struct {
+		key    string
+		weight float64
+		candle *data.Candle
+	}
+	contributions := make([]*contribution, 0, len(candles))
+	var reference *data.Candle
+	for _, source := range config.AllSources() {
+		candle, ok := candles[source.Key()]
+		if !ok {
+			continue
+		}
+		weight := source.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		contributions = append(contributions, &contribution{key: source.Key(), weight: weight, candle: candle})
+		reference = candle
+	}
+	if len(contributions) == 0 {
+		return nil
+	}
+	closes := make([]float64, len(contributions))
+	weights := make([]float64, len(contributions))
+	for i, c := range contributions {
+		closes[i] = c.candle.Close
+		weights[i] = c.weight
+	}
+	medianClose := weightedMedian(closes, weights)
+	included := make([]*contribution, 0, len(contributions))
+	for _, c := range contributions {
+		maxDeviation := DefaultMaxDeviation
+		for _, source := range config.AllSources() {
+			if source.Key() == c.key && source.MaxDeviation > 0 {
+				maxDeviation = source.MaxDeviation
+			}
+		}
+		deviation := math.Abs(c.candle.Close-medianClose) / medianClose * 100
+		if medianClose == 0 || deviation > maxDeviation {
+			log.Warnf("excluding %s from %s consensus at %d, close %f deviates %.3f%% from %f", c.key, config.MarketId, c.candle.ClosingTimestamp, c.candle.Close, deviation, medianClose)
+			continue
+		}
+		included = append(included, c)
+	}
+	if len(included) == 0 || len(included) < config.MinSources {
+		return nil
+	}
+	field := func(get func(*data.Candle) float64) float64 {
+		values := make([]float64, len(included))
+		weights := make([]float64, len(included))
+		for i, c := range included {
+			values[i] = get(c.candle)
+			weights[i] = c.weight
+		}
+		return weightedMedian(values, weights)
+	}
+	openPrice := field(func(c *data.Candle) float64 { return c.Open })
+	closePrice := field(func(c *data.Candle) float64 { return c.Close })
+	highPrice := max(field(func(c *data.Candle) float64 { return c.High }), openPrice, closePrice)
+	lowPrice := min(field(func(c *data.Candle) float64 { return c.Low }), openPrice, closePrice)
+	candle := data.NewCandle(
+		config.Symbol,
+		config.MarketId,
+		60,
+		reference.ClosingTimestamp,
+		reference.OpeningTimestamp,
+		openPrice,
+		closePrice,
+		highPrice,
+		lowPrice,
+		field(func(c *data.Candle) float64 { return c.Volume }),
+		field(func(c *data.Candle) float64 { return c.Turnover }),
+	)
+	for _, c := range included {
+		candle.Sources = append(candle.Sources, c.key)
+	}
+	slices.Sort(candle.Sources)
+	return candle
+}
+
+func weightedMedian(values []float64, weights []float64) float64 {
+	indexes := make([]int, len(values))
+	total := 0.0
+	for i := range values {
+		indexes[i] = i
+		total += weights[i]
+	}
+	sort.Slice(indexes, func(a, b int) bool {
+		return values[indexes[a]] < values[indexes[b]]
+	})
+	cumulative := 0.0
+	for n, i := range indexes {
+		cumulative += weights[i]
+		if cumulative*2 == total && n+1 < len(indexes) {
+			return (values[i] + values[indexes[n+1]]) / 2
+		}
+		if cumulative*2 > total {
+			return values[i]
+		}
+	}
+	return values[indexes[len(indexes)-1]]
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"candles-api/data"
+	"slices"
+	"testing"
+)
+
+func TestConsensus(t *testing.T) {
+	config := &Config{
+		MarketId:   "eur",
+		Symbol:     "EUR-USD",
+		MinSources: 2,
+		Sources: []*Source{
+			{PriceSource: "polygon", Symbol: "EUR-USD", Weight: 1},
+			{PriceSource: "twelve-data", Symbol: "EUR/USD", Weight: 1},
+			{PriceSource: "bybit", Symbol: "EURUSDT", Weight: 1, MaxDeviation: 0.5},
+		},
+	}
+	keys := make([]string, 0)
+	for _, source := range config.Sources {
+		keys = append(keys, source.Key())
+	}
+
+	candle := Consensus(config, map[string]*data.Candle{
+		keys[0]: candleAt(120000, 1.10),
+		keys[1]: candleAt(120000, 1.11),
+		keys[2]: candleAt(120000, 1.115),
+	})
+	if candle == nil || candle.Close != 1.11 || candle.MarketId != "eur" || candle.ClosingTimestamp != 120000 {
+		t.Fatalf("expected median close 1.11, got %+v", candle)
+	}
+	if len(candle.Sources) != 3 {
+		t.Fatalf("expected all sources, got %v", candle.Sources)
+	}
+
+	candle = Consensus(config, map[string]*data.Candle{
+		keys[0]: candleAt(120000, 1.10),
+		keys[1]: candleAt(120000, 1.10),
+		keys[2]: candleAt(120000, 1.20),
+	})
+	expected := []string{keys[0], keys[1]}
+	slices.Sort(expected)
+	if candle == nil || candle.Close != 1.10 || !slices.Equal(candle.Sources, expected) {
+		t.Fatalf("expected outlier excluded, got %+v", candle)
+	}
+
+	candle = Consensus(config, map[string]*data.Candle{
+		keys[0]: candleAt(120000, 1.10),
+	})
+	if candle != nil {
+		t.Fatalf("expected nil below minSources, got %+v", candle)
+	}
+}
+
+func TestWeightedMedian(t *testing.T) {
+	if m := weightedMedian([]float64{1, 2, 3}, []float64{1, 1, 5}); m != 3 {
+		t.Fatalf("expected 3, got %f", m)
+	}
+	if m := weightedMedian([]float64{1, 3}, []float64{1, 1}); m != 2 {
+		t.Fatalf("expected 2, got %f", m)
+	}
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/schedule"
+	"context"
+	"github.com/charmbracelet/log"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultStaleAfter     = time.Minute * 5
+	FailoverCheckInterval = time.Second * 10
+)
+
+type SourceStatus struct {
+	Source               PriceSource `json:"source"`
+	Symbol               string      `json:"symbol"`
+	Active               bool        `json:"active"`
+	LastClosingTimestamp uint64      `json:"lastClosingTimestamp"`
+}
+
+type FailoverStatus struct {
+	MarketId             string          `json:"marketId"`
+	Primary              string          `json:"primary"`
+	Active               string          `json:"active"`
+	FailedOver           bool            `json:"failedOver"`
+	Stale                bool            `json:"stale"`
+	LastClosingTimestamp uint64          `json:"lastClosingTimestamp"`
+	LastSwitch           int64           `json:"lastSwitch,omitempty"`
+	Reason               string          `json:"reason,omitempty"`
+	Switches             int             `json:"switches"`
+	Sources              []*SourceStatus `json:"sources"`
+}
+
+type failoverState struct {
+	active     int
+	switchedAt time.Time
+	reason     string
+	switches   int
+}
+
+type failoverTracker struct {
+	states   map[string]*failoverState
+	lastSeen map[string]map[string]uint64
+	lock     sync.Mutex
+}
+
+func failoverChain(config *Config) []*Source {
+	if config.IsConsensus() {
+		return config.AllSources()
+	}
+	return slices.Concat(config.AllSources(), config.Fallbacks)
+}
+
+func staleAfter(config *Config) time.Duration {
+	if config.StaleAfter > 0 {
+		return config.StaleAfter
+	}
+	return DefaultStaleAfter
+}
+
+func (s *Store) activeSources(config *Config) []*Source {
+	if config.IsConsensus() || len(config.Fallbacks) == 0 {
+		return config.AllSources()
+	}
+	chain := failoverChain(config)
+	s.failover.lock.Lock()
+	defer s.failover.lock.Unlock()
+	state := s.failover.states[config.MarketId]
+	if state == nil || state.active >= len(chain) {
+		return chain[:1]
+	}
+	return chain[state.active : state.active+1]
+}
+
+// pollSources returns the sources to fetch for a market: the active ones,
+// plus the primary while failed over so its recovery can be noticed.
+func (s *Store) pollSources(config *Config) []*Source {
+	active := s.activeSources(config)
+	primary := config.AllSources()[0]
+	if config.IsConsensus() || active[0].Key() == primary.Key() {
+		return active
+	}
+	return []*Source{active[0], primary}
+}
+
+func (s *Store) receive(config *Config, source *Source, candles []*data.Candle) {
+	s.recordSeen(config, source, candles)
+	for _, active := range s.activeSources(config) {
+		if active.Key() == source.Key() {
+			s.ingest(config, source, candles)
+			return
+		}
+	}
+}
+
+func (s *Store) recordSeen(config *Config, source *Source, candles []*data.Candle) {
+	newest := uint64(0)
+	for _, candle := range candles {
+		if candle.Interval == 60 {
+			newest = max(newest, candle.ClosingTimestamp)
+		}
+	}
+	if newest == 0 {
+		return
+	}
+	s.failover.lock.Lock()
+	defer s.failover.lock.Unlock()
+	seen := s.failover.lastSeen[config.MarketId]
+	if seen == nil {
+		seen = map[string]uint64{}
+		s.failover.lastSeen[config.MarketId] = seen
+	}
+	seen[source.Key()] = max(seen[source.Key()], newest)
+}
+
+func (s *Store) newestClosingTimestamp(marketId string) uint64 {
+	latest := s.candles.Latest(marketId, 60, 1)
+	if len(latest) == 0 {
+		return 0
+	}
+	return latest[0].ClosingTimestamp
+}
+
+func (s *Store) MonitorFailover(ctx context.Context) {
+	s.every(ctx, FailoverCheckInterval, func(ctx context.Context) {
+		for _, config := range s.Config() {
+			s.checkFailover(config, s.clock.Now())
+		}
+	})
+}
+
+func (s *Store) checkFailover(config *Config, now time.Time) {
+	if config.IsConsensus() || len(config.Fallbacks) == 0 {
+		return
+	}
+	sched, err := schedule.Parse(config.Schedule)
+	if err != nil {
+		log.Errorf("cannot parse schedule for %s %v", config.MarketId, err)
+		return
+	}
+	chain := failoverChain(config)
+	threshold := staleAfter(config)
+	newest := s.newestClosingTimestamp(config.MarketId)
+	s.failover.lock.Lock()
+	defer s.failover.lock.Unlock()
+	state := s.failover.states[config.MarketId]
+	if state == nil || state.active >= len(chain) {
+		state = &failoverState{switchedAt: now}
+		s.failover.states[config.MarketId] = state
+	}
+	if !sched.IsOpen(now) {
+		return
+	}
+	if state.active != 0 {
+		primary := chain[0]
+		seen := s.failover.lastSeen[config.MarketId][primary.Key()]
+		if seen > 0 && now.Sub(time.UnixMilli(int64(seen))) <= threshold {
+			s.switchSource(config, state, chain, 0, now, "primary recovered")
+			return
+		}
+	}
+	reference := max(int64(newest), state.switchedAt.UnixMilli())
+	age := now.Sub(time.UnixMilli(reference))
+	if age <= threshold {
+		return
+	}
+	next := (state.active + 1) % len(chain)
+	if next == state.active {
+		return
+	}
+	s.switchSource(config, state, chain, next, now, chain[state.active].Key()+" stale for "+age.Truncate(time.Second).String())
+}
+
+func (s *Store) switchSource(config *Config, state *failoverState, chain []*Source, next int, now time.Time, reason string) {
+	log.Warnf("market %s (%s) switching from %s to %s: %s", config.MarketId, config.Symbol, chain[state.active].Key(), chain[next].Key(), reason)
+	state.active = next
+	state.switchedAt = now
+	state.reason = reason
+	state.switches++
+}
+
+func (s *Store) GetFailover(config *Config, now time.Time) *FailoverStatus {
+	chain := failoverChain(config)
+	active := s.activeSources(config)
+	newest := s.newestClosingTimestamp(config.MarketId)
+	status := &FailoverStatus{
+		MarketId:             config.MarketId,
+		Primary:              chain[0].Key(),
+		LastClosingTimestamp: newest,
+		Stale:                s.isStale(config, newest, now),
+		Sources:              make([]*SourceStatus, 0, len(chain)),
+	}
+	s.failover.lock.Lock()
+	defer s.failover.lock.Unlock()
+	if state := s.failover.states[config.MarketId]; state != nil {
+		status.Reason = state.reason
+		status.Switches = state.switches
+		if state.switches > 0 {
+			status.LastSwitch = state.switchedAt.UnixMilli()
+		}
+	}
+	for _, source := range chain {
+		isActive := slices.ContainsFunc(active, func(a *Source) bool {
+			return a.Key() == source.Key()
+		})
+		if isActive && len(status.Active) == 0 {
+			status.Active = source.Key()
+		}
+		status.Sources = append(status.Sources, &SourceStatus{
+			Source:               source.PriceSource,
+			Symbol:               source.Symbol,
+			Active:               isActive,
+			LastClosingTimestamp: s.failover.lastSeen[config.MarketId][source.Key()],
+		})
+	}
+	status.FailedOver = status.Active != status.Primary
+	return status
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"testing"
+	"time"
+)
+
+func TestStore_Failover(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	config := &Config{
+		MarketId:    "eur",
+		PriceSource: "polygon",
+		Symbol:      "EUR-USD",
+		Schedule:    "24/7",
+		StaleAfter:  time.Minute * 5,
+		Fallbacks:   []*Source{{PriceSource: "twelve-data", Symbol: "EUR/USD", Weight: 1}},
+	}
+	s := NewStore(testIntervals, []*Config{config}, provider.NewRegistry(), nil, nil)
+	primary := config.AllSources()[0]
+	fallback := config.Fallbacks[0]
+	at := func(t time.Time) *data.Candle {
+		ts := uint64(t.UnixMilli())
+		return data.NewCandle("EUR-USD", "eur", 60, ts, ts-60000, 1, 1, 1, 1, 0, 0)
+	}
+
+	s.receive(config, primary, []*data.Candle{at(now)})
+	s.checkFailover(config, now.Add(time.Minute*4))
+	if active := s.activeSources(config); active[0].Key() != primary.Key() {
+		t.Fatalf("expected primary to stay active, got %s", active[0].Key())
+	}
+
+	s.checkFailover(config, now.Add(time.Minute*10))
+	if active := s.activeSources(config); active[0].Key() != fallback.Key() {
+		t.Fatalf("expected failover to %s, got %s", fallback.Key(), active[0].Key())
+	}
+	if sources := s.pollSources(config); len(sources) != 2 || sources[1].Key() != primary.Key() {
+		t.Fatalf("expected the primary to keep being polled, got %v", sources)
+	}
+
+	s.receive(config, primary, []*data.Candle{at(now.Add(time.Minute * 3))})
+	if latest := s.GetLatestCandles("eur", 60, 1); latest[0].ClosingTimestamp != uint64(now.UnixMilli()) {
+		t.Fatal("expected candles from the inactive primary to be discarded")
+	}
+	s.receive(config, fallback, []*data.Candle{at(now.Add(time.Minute * 10))})
+	if latest := s.GetLatestCandles("eur", 60, 1); latest[0].ClosingTimestamp != uint64(now.Add(time.Minute*10).UnixMilli()) {
+		t.Fatal("expected candles from the active fallback to be saved")
+	}
+
+	s.receive(config, primary, []*data.Candle{at(now.Add(time.Minute * 11))})
+	s.checkFailover(config, now.Add(time.Minute*12))
+	status := s.GetFailover(config, now.Add(time.Minute*12))
+	if status.FailedOver || status.Active != primary.Key() || status.Switches != 2 || status.Reason != "primary recovered" {
+		t.Fatalf("expected switchback to primary, got %+v", status)
+	}
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"candles-api/schedule"
+	"context"
+	"github.com/charmbracelet/log"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	GapScanInterval    = time.Minute
+	GapLookback        = time.Hour * 24
+	GapSettleTime      = time.Minute * 2
+	MaxRepairAttempts  = 3
+	MaxRepairsPerScan  = 5
+	RepairedGapHistory = 100
+)
+
+type Gap struct {
+	From        uint64 `json:"from"`
+	To          uint64 `json:"to"`
+	Minutes     int    `json:"minutes"`
+	Attempts    int    `json:"attempts"`
+	LastAttempt int64  `json:"lastAttempt,omitempty"`
+	LastError   string `json:"lastError,omitempty"`
+	RepairedAt  int64  `json:"repairedAt,omitempty"`
+}
+
+type GapReport struct {
+	MarketId     string `json:"marketId"`
+	LastScan     int64  `json:"lastScan"`
+	Missing      []*Gap `json:"missing"`
+	Unrepairable []*Gap `json:"unrepairable"`
+	Repaired     []*Gap `json:"repaired"`
+}
+
+type gapTracker struct {
+	reports map[string]*GapReport
+	lock    sync.Mutex
+}
+
+func (s *Store) GetGaps(marketId string) *GapReport {
+	s.gaps.lock.Lock()
+	defer s.gaps.lock.Unlock()
+	report := s.gaps.reports[marketId]
+	if report == nil {
+		return &GapReport{MarketId: marketId, Missing: []*Gap{}, Unrepairable: []*Gap{}, Repaired: []*Gap{}}
+	}
+	return &GapReport{
+		MarketId:     report.MarketId,
+		LastScan:     report.LastScan,
+		Missing:      copyGaps(report.Missing),
+		Unrepairable: copyGaps(report.Unrepairable),
+		Repaired:     copyGaps(report.Repaired),
+	}
+}
+
+func copyGaps(gaps []*Gap) []*Gap {
+	copied := make([]*Gap, 0, len(gaps))
+	for _, gap := range gaps {
+		g := *gap
+		copied = append(copied, &g)
+	}
+	return copied
+}
+
+func (s *Store) RepairGaps(ctx context.Context) {
+	s.every(ctx, GapScanInterval, func(ctx context.Context) {
+		for _, config := range s.Config() {
+			if ctx.Err() != nil {
+				return
+			}
+			s.repairGaps(ctx, config, s.clock.Now())
+		}
+	})
+}
+
+func (s *Store) FindGaps(config *Config, now time.Time) []*Gap {
+	sched, err := schedule.Parse(config.Schedule)
+	if err != nil {
+		log.Errorf("cannot parse schedule of %s %v", config.MarketId, err)
+		return nil
+	}
+	end := now.Add(-GapSettleTime).Truncate(time.Minute)
+	start := now.Add(-GapLookback).Truncate(time.Minute)
+	for _, interval := range s.Intervals() {
+		if interval.Seconds == 60 && now.Add(-interval.Retention).After(start) {
+			start = now.Add(-interval.Retention).Truncate(time.Minute).Add(time.Minute)
+		}
+	}
+	candles := s.candles.Range(config.MarketId, 60, uint64(start.UnixMilli())+60000, uint64(end.UnixMilli()))
+	if len(candles) == 0 {
+		return nil
+	}
+	present := make(map[uint64]bool, len(candles))
+	for _, candle := range candles {
+		present[candle.OpeningTimestamp] = true
+	}
+	gaps := make([]*Gap, 0)
+	var current *Gap
+	for minute := start; minute.Before(end); minute = minute.Add(time.Minute) {
+		ts := uint64(minute.UnixMilli())
+		if present[ts] || !sched.IsOpen(minute) {
+			current = nil
+			continue
+		}
+		if current == nil {
+			current = &Gap{From: ts}
+			gaps = append(gaps, current)
+		}
+		current.To = ts + 60000
+		current.Minutes++
+	}
+	return gaps
+}
+
+func (s *Store) repairGaps(ctx context.Context, config *Config, now time.Time) {
+	found := s.FindGaps(config, now)
+	s.gaps.lock.Lock()
+	report := s.gaps.reports[config.MarketId]
+	if report == nil {
+		report = &GapReport{MarketId: config.MarketId}
+		s.gaps.reports[config.MarketId] = report
+	}
+	previous := map[uint64]*Gap{}
+	for _, gap := range slices.Concat(report.Missing, report.Unrepairable) {
+		previous[gap.From] = gap
+	}
+	report.LastScan = now.UnixMilli()
+	report.Missing = make([]*Gap, 0)
+	report.Unrepairable = make([]*Gap, 0)
+	for _, gap := range found {
+		if old, ok := previous[gap.From]; ok {
+			gap.Attempts = old.Attempts
+			gap.LastAttempt = old.LastAttempt
+			gap.LastError = old.LastError
+		}
+		if gap.Attempts >= MaxRepairAttempts {
+			report.Unrepairable = append(report.Unrepairable, gap)
+		} else {
+			report.Missing = append(report.Missing, gap)
+		}
+	}
+	pending := make([]*Gap, 0, MaxRepairsPerScan)
+	for _, gap := range report.Missing {
+		if len(pending) == MaxRepairsPerScan {
+			break
+		}
+		copied := *gap
+		pending = append(pending, &copied)
+	}
+	s.gaps.lock.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	for _, gap := range pending {
+		var err error
+		for _, source := range s.activeSources(config) {
+			p, ok := s.providers.Get(string(source.PriceSource))
+			if !ok {
+				continue
+			}
+			candles, fetchErr := p.GetCandles(ctx, source.Symbol, source.MicCode, 60, time.UnixMilli(int64(gap.From)), time.UnixMilli(int64(gap.To)-1))
+			if fetchErr != nil {
+				err = fetchErr
+			}
+			s.ingest(config, source, candles)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		filled := true
+		for ts := gap.From; ts < gap.To; ts += 60000 {
+			if len(s.candles.Range(config.MarketId, 60, ts+60000, ts+60000)) == 0 {
+				filled = false
+				break
+			}
+		}
+		s.recordRepair(config.MarketId, gap.From, filled, err, now)
+	}
+}
+
+func (s *Store) recordRepair(marketId string, from uint64, filled bool, err error, now time.Time) {
+	s.gaps.lock.Lock()
+	defer s.gaps.lock.Unlock()
+	report := s.gaps.reports[marketId]
+	i := slices.IndexFunc(report.Missing, func(gap *Gap) bool {
+		return gap.From == from
+	})
+	if i < 0 {
+		return
+	}
+	gap := report.Missing[i]
+	gap.Attempts++
+	gap.LastAttempt = now.UnixMilli()
+	gap.LastError = ""
+	if err != nil {
+		gap.LastError = err.Error()
+	}
+	if filled {
+		log.Infof("repaired %d minute gap in %s from %d", gap.Minutes, marketId, gap.From)
+		gap.RepairedAt = now.UnixMilli()
+		report.Missing = slices.Delete(report.Missing, i, i+1)
+		report.Repaired = append(report.Repaired, gap)
+		if len(report.Repaired) > RepairedGapHistory {
+			report.Repaired = report.Repaired[len(report.Repaired)-RepairedGapHistory:]
+		}
+		return
+	}
+	if gap.Attempts >= MaxRepairAttempts {
+		log.Warnf("giving up on %d minute gap in %s from %d after %d attempts", gap.Minutes, marketId, gap.From, gap.Attempts)
+		report.Missing = slices.Delete(report.Missing, i, i+1)
+		report.Unrepairable = append(report.Unrepairable, gap)
+	}
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"candles-api/schedule"
+	"sync"
+	"time"
+)
+
+type fetchStatus struct {
+	lastFetch   time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+type fetchTracker struct {
+	markets map[string]*fetchStatus
+	lock    sync.Mutex
+}
+
+type MarketState struct {
+	MarketId             string `json:"marketId"`
+	Symbol               string `json:"symbol"`
+	LastClosingTimestamp uint64 `json:"lastClosingTimestamp,omitempty"`
+}
+
+type ReadinessReport struct {
+	Ready    bool           `json:"ready"`
+	Stale    []*MarketState `json:"stale"`
+	Empty    []*MarketState `json:"empty"`
+	Unsynced []*MarketState `json:"unsynced"`
+}
+
+func (s *Store) recordFetch(config *Config, source *Source, err error, now time.Time) {
+	s.fetches.lock.Lock()
+	defer s.fetches.lock.Unlock()
+	status := s.fetches.markets[config.MarketId]
+	if status == nil {
+		status = &fetchStatus{}
+		s.fetches.markets[config.MarketId] = status
+	}
+	if err != nil {
+		status.lastError = source.Key() + ": " + err.Error()
+		status.lastErrorAt = now
+		return
+	}
+	status.lastFetch = now
+}
+
+func (s *Store) lastFetch(marketId string) fetchStatus {
+	s.fetches.lock.Lock()
+	defer s.fetches.lock.Unlock()
+	if status := s.fetches.markets[marketId]; status != nil {
+		return *status
+	}
+	return fetchStatus{}
+}
+
+// isStale reports whether a market that should be trading has gone without
+// a new 1m candle for longer than its stale threshold. Markets that are
+// closed, or only opened within the threshold, are never stale.
+func (s *Store) isStale(config *Config, newest uint64, now time.Time) bool {
+	sched, err := schedule.Parse(config.Schedule)
+	if err != nil {
+		return false
+	}
+	threshold := staleAfter(config)
+	if !sched.IsOpen(now) || !sched.IsOpen(now.Add(-threshold)) {
+		return false
+	}
+	return now.Sub(time.UnixMilli(int64(newest))) > threshold
+}
+
+func (s *Store) Readiness(now time.Time) *ReadinessReport {
+	report := &ReadinessReport{
+		Stale:    make([]*MarketState, 0),
+		Empty:    make([]*MarketState, 0),
+		Unsynced: make([]*MarketState, 0),
+	}
+	for _, config := range s.Config() {
+		newest := s.newestClosingTimestamp(config.MarketId)
+		state := &MarketState{MarketId: config.MarketId, Symbol: config.Symbol, LastClosingTimestamp: newest}
+		if s.lastFetch(config.MarketId).lastFetch.IsZero() {
+			report.Unsynced = append(report.Unsynced, state)
+		}
+		if newest == 0 {
+			report.Empty = append(report.Empty, state)
+		} else if s.isStale(config, newest, now) {
+			report.Stale = append(report.Stale, state)
+		}
+	}
+	report.Ready = len(report.Stale) == 0 && len(report.Empty) == 0 && len(report.Unsynced) == 0
+	return report
+}
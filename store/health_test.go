@@ -0,0 +1,66 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStore_Readiness(t *testing.T) {
+	now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	btc := &Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT", Schedule: "24/7"}
+	lse := &Config{MarketId: "lse", PriceSource: "twelve-data", Symbol: "VOD", Schedule: "Mon-Fri 08:00-16:30 Europe/London"}
+	s := NewStore(testIntervals, []*Config{btc, lse}, provider.NewRegistry(), nil, nil)
+
+	report := s.Readiness(now)
+	if report.Ready || len(report.Empty) != 2 || len(report.Unsynced) != 2 {
+		t.Fatalf("expected an empty store not to be ready, got %+v", report)
+	}
+
+	s.recordFetch(btc, btc.AllSources()[0], nil, now)
+	s.recordFetch(lse, lse.AllSources()[0], errors.New("unavailable"), now)
+	ts := uint64(now.Add(-time.Minute * 10).UnixMilli())
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, ts, ts-60000, 1, 1, 1, 1, 0, 0))
+	s.SaveCandle(data.NewCandle("VOD", "lse", 60, ts, ts-60000, 1, 1, 1, 1, 0, 0))
+	report = s.Readiness(now)
+	if report.Ready || len(report.Empty) != 0 || len(report.Stale) != 2 || len(report.Unsynced) != 1 || report.Unsynced[0].MarketId != "lse" {
+		t.Fatalf("expected stale markets and an unsynced lse, got %+v", report)
+	}
+
+	s.recordFetch(lse, lse.AllSources()[0], nil, now)
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, ts+540000, ts+480000, 1, 1, 1, 1, 0, 0))
+	evening := time.Date(2024, 1, 3, 20, 0, 0, 0, time.UTC)
+	if report = s.Readiness(now); report.Ready || len(report.Stale) != 1 || report.Stale[0].MarketId != "lse" {
+		t.Fatalf("expected only lse to be stale, got %+v", report)
+	}
+	if report = s.Readiness(evening); len(report.Stale) != 1 || report.Stale[0].MarketId != "btc" {
+		t.Fatalf("expected a closed market not to be stale, got %+v", report)
+	}
+}
+
+func TestStore_ReadinessWithClosedAndStaleMarkets(t *testing.T) {
+	// 20:00 UTC is 15:00 in New York, after the orange juice session closed
+	now := time.Date(2024, 1, 3, 20, 0, 0, 0, time.UTC)
+	juice := &Config{MarketId: "jo1", PriceSource: "twelve-data", Symbol: "JO1", Schedule: "Mon-Fri 08:00-14:00 America/New_York"}
+	btc := &Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT", Schedule: "24/7"}
+	s := NewStore(testIntervals, []*Config{juice, btc}, provider.NewRegistry(), nil, nil)
+	s.recordFetch(juice, juice.AllSources()[0], nil, now)
+	s.recordFetch(btc, btc.AllSources()[0], nil, now)
+	closed := uint64(time.Date(2024, 1, 3, 19, 0, 0, 0, time.UTC).UnixMilli())
+	s.SaveCandle(data.NewCandle("JO1", "jo1", 60, closed, closed-60000, 1, 1, 1, 1, 0, 0))
+	stale := uint64(now.Add(-time.Minute * 10).UnixMilli())
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, stale, stale-60000, 1, 1, 1, 1, 0, 0))
+
+	report := s.Readiness(now)
+	if report.Ready || len(report.Stale) != 1 || report.Stale[0].MarketId != "btc" {
+		t.Fatalf("expected the stale btc alone to fail readiness, got %+v", report)
+	}
+
+	fresh := uint64(now.UnixMilli())
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, fresh, fresh-60000, 1, 1, 1, 1, 0, 0))
+	if report = s.Readiness(now); !report.Ready || len(report.Stale) != 0 {
+		t.Fatalf("expected a market closed by its schedule not to fail readiness, got %+v", report)
+	}
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"candles-api/clock"
+	"candles-api/data"
+	"candles-api/provider"
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStore_GetStartingTimestampsForInterval(t *testing.T) {
+	s := NewStore(testIntervals, []*Config{}, provider.NewRegistry(), nil, nil)
+	tests := []struct {
+		name     string
+		interval uint64
+		first    uint64
+		last     uint64
+		expected []uint64
+	}{
+		{"last before first", 300, 600000, 300000, []uint64{}},
+		{"before the first minute", 300, 0, 0, []uint64{0}},
+		{"first minute", 300, 60000, 60000, []uint64{0}},
+		{"closing on the boundary", 300, 300000, 300000, []uint64{0}},
+		{"minute after the boundary", 300, 360000, 360000, []uint64{300000}},
+		{"spanning buckets", 300, 60000, 660000, []uint64{0, 300000, 600000}},
+		{"same as the interval", 60, 120000, 240000, []uint64{60000, 120000, 180000}},
+		{"hourly", 3600, 3600000, 7260000, []uint64{0, 3600000, 7200000}},
+		{"daily", 86400, 86400000, 86460000, []uint64{0, 86400000}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timestamps := s.GetStartingTimestampsForInterval(test.interval, test.first, test.last)
+			if !slices.Equal(timestamps, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, timestamps)
+			}
+		})
+	}
+}
+
+func startLoops(t *testing.T, s *Store, m *clock.Manual, loops ...func(ctx context.Context)) {
+	for _, loop := range loops {
+		loop(context.Background())
+	}
+	m.BlockUntil(len(loops))
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+}
+
+func TestStore_AggregateCandles(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	start := uint64(now.UnixMilli())
+	m := clock.NewManual(now)
+	btc := &Config{MarketId: "btc", PriceSource: Bybit, Symbol: "BTCUSDT"}
+	intervals := []*Interval{
+		{Seconds: 60, Retention: time.Hour * 24},
+		{Seconds: 300, Retention: time.Hour * 24},
+		{Seconds: 3600, Retention: time.Hour * 24},
+	}
+	s := NewStore(intervals, []*Config{btc}, provider.NewRegistry(), nil, nil).WithClock(m)
+	startLoops(t, s, m, s.AggregateCandles)
+
+	for i := uint64(0); i < 7; i++ {
+		opening := start + i*60000
+		s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, opening+60000, opening, float64(10+i), float64(11+i), float64(12+i), float64(9+i), 1, 10))
+	}
+	if len(s.GetCandles("btc", 300, 1, 0)) != 0 {
+		t.Fatal("expected no aggregation before the clock advances")
+	}
+	m.Advance(time.Second)
+
+	fiveMinutes := s.GetCandles("btc", 300, 1, 0)
+	expected := []*data.Candle{
+		data.NewCandle("BTCUSDT", "btc", 300, start+600000, start+300000, 15, 17, 18, 14, 2, 20),
+		data.NewCandle("BTCUSDT", "btc", 300, start+300000, start, 10, 15, 16, 9, 5, 50),
+	}
+	if len(fiveMinutes) != len(expected) {
+		t.Fatalf("expected %d 5m candles, got %+v", len(expected), fiveMinutes)
+	}
+	for i := range expected {
+		if !fiveMinutes[i].Equal(expected[i]) {
+			t.Fatalf("expected %+v, got %+v", expected[i], fiveMinutes[i])
+		}
+	}
+	hourly := s.GetCandles("btc", 3600, 1, 0)
+	if len(hourly) != 1 || !hourly[0].Equal(data.NewCandle("BTCUSDT", "btc", 3600, start+3600000, start, 10, 17, 18, 9, 7, 70)) {
+		t.Fatalf("unexpected hourly candles %+v", hourly)
+	}
+
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, start+480000, start+420000, 17, 30, 31, 16, 1, 10))
+	m.Advance(time.Second)
+	if latest := s.GetCandles("btc", 300, start+600000, start+600000); len(latest) != 1 || latest[0].Close != 30 || latest[0].High != 31 || latest[0].Volume != 3 {
+		t.Fatalf("expected the open bucket to be re-aggregated, got %+v", latest)
+	}
+}
+
+func TestStore_ArchiveCandles(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := clock.NewManual(now)
+	btc := &Config{MarketId: "btc", PriceSource: Bybit, Symbol: "BTCUSDT"}
+	eth := &Config{MarketId: "eth", PriceSource: Bybit, Symbol: "ETHUSDT"}
+	intervals := []*Interval{
+		{Seconds: 60, Retention: time.Hour},
+		{Seconds: 300, Retention: time.Hour * 2},
+	}
+	s := NewStore(intervals, []*Config{btc, eth}, provider.NewRegistry(), nil, nil).WithClock(m)
+	startLoops(t, s, m, s.ArchiveCandles)
+
+	for minute := now.Add(-time.Minute * 150); minute.Before(now); minute = minute.Add(time.Minute) {
+		opening := uint64(minute.UnixMilli())
+		s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, opening+60000, opening, 1, 1, 1, 1, 0, 0))
+		s.SaveCandle(data.NewCandle("ETHUSDT", "eth", 60, opening+60000, opening, 1, 1, 1, 1, 0, 0))
+		if minute.Minute()%5 == 0 {
+			s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 300, opening+300000, opening, 1, 1, 1, 1, 0, 0))
+		}
+	}
+
+	m.Advance(time.Second)
+	oneMinute := s.GetCandles("btc", 60, 1, 0)
+	if len(oneMinute) != 60 || oneMinute[len(oneMinute)-1].ClosingTimestamp != uint64(now.Add(-time.Minute*59).UnixMilli()) {
+		t.Fatalf("expected 1m candles closing within the last hour, got %d from %d", len(oneMinute), oneMinute[len(oneMinute)-1].ClosingTimestamp)
+	}
+	fiveMinutes := s.GetCandles("btc", 300, 1, 0)
+	if len(fiveMinutes) != 24 || fiveMinutes[len(fiveMinutes)-1].ClosingTimestamp != uint64(now.Add(-time.Minute*115).UnixMilli()) {
+		t.Fatalf("expected 5m candles closing within the last two hours, got %d", len(fiveMinutes))
+	}
+
+	m.Advance(time.Minute * 30)
+	if oneMinute := s.GetCandles("btc", 60, 1, 0); len(oneMinute) != 30 {
+		t.Fatalf("expected retention to follow the clock, got %d 1m candles", len(oneMinute))
+	}
+
+	s.Reload(intervals, []*Config{btc})
+	m.Advance(RemovedMarketGracePeriod - time.Second)
+	if len(s.GetCandles("eth", 60, 1, 0)) == 0 {
+		t.Fatal("expected eth candles to survive the grace period")
+	}
+	m.Advance(time.Second)
+	if len(s.GetCandles("eth", 60, 1, 0)) != 0 {
+		t.Fatal("expected eth candles to be purged once the grace period passed")
+	}
+}
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"candles-api/data"
+	"github.com/charmbracelet/log"
+	"github.com/hashicorp/go-memdb"
+	"math"
+)
+
+const candlesTable = "candles"
+
+var memDbSchema = &memdb.DBSchema{
+	Tables: map[string]*memdb.TableSchema{
+		candlesTable: {
+			Name: candlesTable,
+			Indexes: map[string]*memdb.IndexSchema{
+				"id": {
+					Name:   "id",
+					Unique: true,
+					Indexer: &memdb.CompoundIndex{
+						Indexes: []memdb.Indexer{
+							&memdb.StringFieldIndex{Field: "MarketId"},
+							&memdb.UintFieldIndex{Field: "Interval"},
+							&memdb.UintFieldIndex{Field: "ClosingTimestamp"},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+type memDbBackend struct {
+	db *memdb.MemDB
+}
+
+func NewMemDbBackend() (Backend, error) {
+	db, err := memdb.NewMemDB(memDbSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &memDbBackend{db: db}, nil
+}
+
+func (b *memDbBackend) Save(candle *data.Candle) bool {
+	txn := b.db.Txn(true)
+	defer txn.Abort()
+	existing, err := txn.First(candlesTable, "id", candle.MarketId, candle.Interval, candle.ClosingTimestamp)
+	if err != nil {
+		log.Errorf("cannot read candle %s %v", candle.Id, err)
+		return false
+	}
+	if existing != nil && existing.(*data.Candle).Equal(candle) {
+		return false
+	}
+	err = txn.Insert(candlesTable, candle)
+	if err != nil {
+		log.Errorf("cannot save candle %s %v", candle.Id, err)
+		return false
+	}
+	txn.Commit()
+	return true
+}
+
+func (b *memDbBackend) Remove(marketId string, interval uint64, closingTimestamp uint64) bool {
+	txn := b.db.Txn(true)
+	defer txn.Abort()
+	existing, err := txn.First(candlesTable, "id", marketId, interval, closingTimestamp)
+	if err != nil || existing == nil {
+		return false
+	}
+	err = txn.Delete(candlesTable, existing)
+	if err != nil {
+		log.Errorf("cannot remove candle %s %v", existing.(*data.Candle).Id, err)
+		return false
+	}
+	txn.Commit()
+	return true
+}
+
+func (b *memDbBackend) Range(marketId string, interval uint64, fromTimestamp uint64, toTimestamp uint64) []*data.Candle {
+	return b.scan(b.db.Txn(false), marketId, interval, fromTimestamp, toTimestamp)
+}
+
+func (b *memDbBackend) scan(txn *memdb.Txn, marketId string, interval uint64, fromTimestamp uint64, toTimestamp uint64) []*data.Candle {
+	if toTimestamp == 0 {
+		toTimestamp = math.MaxUint64
+	}
+	it, err := txn.LowerBound(candlesTable, "id", marketId, interval, fromTimestamp)
+	if err != nil {
+		log.Errorf("cannot scan candles %v", err)
+		return nil
+	}
+	candles := make([]*data.Candle, 0)
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		candle := obj.(*data.Candle)
+		if candle.MarketId != marketId || candle.Interval != interval || candle.ClosingTimestamp > toTimestamp {
+			break
+		}
+		candles = append(candles, candle)
+	}
+	return candles
+}
+
+func (b *memDbBackend) Latest(marketId string, interval uint64, n int) []*data.Candle {
+	txn := b.db.Txn(false)
+	it, err := txn.ReverseLowerBound(candlesTable, "id", marketId, interval, uint64(math.MaxUint64))
+	if err != nil {
+		log.Errorf("cannot scan candles %v", err)
+		return nil
+	}
+	candles := make([]*data.Candle, 0, n)
+	for obj := it.Next(); obj != nil && len(candles) < n; obj = it.Next() {
+		candle := obj.(*data.Candle)
+		if candle.MarketId != marketId || candle.Interval != interval {
+			break
+		}
+		candles = append(candles, candle)
+	}
+	for i, j := 0, len(candles)-1; i < j; i, j = i+1, j-1 {
+		candles[i], candles[j] = candles[j], candles[i]
+	}
+	return candles
+}
+
+func (b *memDbBackend) Earliest(marketId string, interval uint64, n int) []*data.Candle {
+	txn := b.db.Txn(false)
+	it, err := txn.LowerBound(candlesTable, "id", marketId, interval, uint64(0))
+	if err != nil {
+		log.Errorf("cannot scan candles %v", err)
+		return nil
+	}
+	candles := make([]*data.Candle, 0, n)
+	for obj := it.Next(); obj != nil && len(candles) < n; obj = it.Next() {
+		candle := obj.(*data.Candle)
+		if candle.MarketId != marketId || candle.Interval != interval {
+			break
+		}
+		candles = append(candles, candle)
+	}
+	return candles
+}
+
+func (b *memDbBackend) Count(marketId string, interval uint64) int {
+	txn := b.db.Txn(false)
+	it, err := txn.LowerBound(candlesTable, "id", marketId, interval, uint64(0))
+	if err != nil {
+		log.Errorf("cannot count candles %v", err)
+		return 0
+	}
+	count := 0
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		candle := obj.(*data.Candle)
+		if candle.MarketId != marketId || candle.Interval != interval {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+func (b *memDbBackend) TrimBefore(marketId string, interval uint64, closingTimestamp uint64) []*data.Candle {
+	if closingTimestamp == 0 {
+		return nil
+	}
+	txn := b.db.Txn(true)
+	defer txn.Abort()
+	trimmed := b.scan(txn, marketId, interval, 0, closingTimestamp-1)
+	for _, candle := range trimmed {
+		err := txn.Delete(candlesTable, candle)
+		if err != nil {
+			log.Errorf("cannot trim candle %s %v", candle.Id, err)
+			return nil
+		}
+	}
+	txn.Commit()
+	return trimmed
+}
+
+func (b *memDbBackend) Purge(marketId string) []*data.Candle {
+	txn := b.db.Txn(true)
+	defer txn.Abort()
+	it, err := txn.Get(candlesTable, "id_prefix", marketId)
+	if err != nil {
+		log.Errorf("cannot purge market %s %v", marketId, err)
+		return nil
+	}
+	purged := make([]*data.Candle, 0)
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		candle := obj.(*data.Candle)
+		if candle.MarketId == marketId {
+			purged = append(purged, candle)
+		}
+	}
+	for _, candle := range purged {
+		_ = txn.Delete(candlesTable, candle)
+	}
+	txn.Commit()
+	return purged
+}
+
+func (b *memDbBackend) All() []*data.Candle {
+	txn := b.db.Txn(false)
+	it, err := txn.Get(candlesTable, "id")
+	if err != nil {
+		log.Errorf("cannot scan candles %v", err)
+		return nil
+	}
+	candles := make([]*data.Candle, 0)
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		candles = append(candles, obj.(*data.Candle))
+	}
+	return candles
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"candles-api/metrics"
+	"context"
+	"strconv"
+	"time"
+)
+
+const MetricsInterval = time.Second * 5
+
+func (s *Store) RecordMetrics(ctx context.Context) {
+	recorded := map[string]string{}
+	s.every(ctx, MetricsInterval, func(ctx context.Context) {
+		recorded = s.recordMetrics(recorded, s.clock.Now())
+	})
+}
+
+// recordMetrics updates the per-market gauges and drops the series of
+// markets that are no longer configured. It returns the symbols of the
+// markets it recorded, keyed by market id.
+func (s *Store) recordMetrics(previous map[string]string, now time.Time) map[string]string {
+	intervals := s.Intervals()
+	recorded := map[string]string{}
+	for _, config := range s.Config() {
+		recorded[config.MarketId] = config.Symbol
+		if symbol, ok := previous[config.MarketId]; ok && symbol != config.Symbol {
+			metrics.LastCandleAge.DeleteLabelValues(config.MarketId, symbol)
+		}
+		if newest := s.newestClosingTimestamp(config.MarketId); newest > 0 {
+			metrics.LastCandleAge.WithLabelValues(config.MarketId, config.Symbol).Set(now.Sub(time.UnixMilli(int64(newest))).Seconds())
+		}
+		for _, interval := range intervals {
+			count := s.candles.Count(config.MarketId, interval.Seconds)
+			metrics.StoredCandles.WithLabelValues(config.MarketId, strconv.FormatUint(interval.Seconds, 10)).Set(float64(count))
+		}
+	}
+	for marketId, symbol := range previous {
+		if _, ok := recorded[marketId]; !ok {
+			metrics.LastCandleAge.DeleteLabelValues(marketId, symbol)
+			metrics.StoredCandles.DeletePartialMatch(map[string]string{"market": marketId})
+		}
+	}
+	return recorded
+}
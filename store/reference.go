@@ -0,0 +1,154 @@
+package store
+
+import (
+	"candles-api/data"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type PricePolicy string
+
+const (
+	PreviousClose PricePolicy = "previous"
+	Interpolated  PricePolicy = "interpolated"
+	Strict        PricePolicy = "strict"
+)
+
+const (
+	PriceLookback          = time.Hour * 24 * 7
+	DefaultMaxStaleMinutes = 5
+)
+
+var (
+	ErrNoPrice         = errors.New("no price available")
+	ErrStalePrice      = errors.New("price is stale")
+	ErrFutureTimestamp = errors.New("timestamp is in the future")
+)
+
+type PriceQuote struct {
+	MarketId  string         `json:"marketId"`
+	Timestamp uint64         `json:"timestamp"`
+	Policy    PricePolicy    `json:"policy"`
+	Price     float64        `json:"price"`
+	Candles   []*data.Candle `json:"candles"`
+}
+
+type AverageQuote struct {
+	MarketId string         `json:"marketId"`
+	From     uint64         `json:"from"`
+	To       uint64         `json:"to"`
+	Twap     float64        `json:"twap"`
+	Vwap     *float64       `json:"vwap"`
+	Candles  []*data.Candle `json:"candles"`
+}
+
+func ParsePricePolicy(value string) (PricePolicy, error) {
+	switch PricePolicy(value) {
+	case "", PreviousClose:
+		return PreviousClose, nil
+	case Interpolated, Strict:
+		return PricePolicy(value), nil
+	default:
+		return "", fmt.Errorf("unknown policy %q, expected %s, %s or %s", value, PreviousClose, Interpolated, Strict)
+	}
+}
+
+// closedCandles returns copies of the 1m candles that closed within
+// [from, to] and no later than now, so a candle that is still forming is
+// never used as a reference.
+func (s *Store) closedCandles(marketId string, from uint64, to uint64, now time.Time) []*data.Candle {
+	to = min(to, uint64(now.UnixMilli()))
+	if to < from {
+		return nil
+	}
+	matching := s.candles.Range(marketId, 60, from, to)
+	candles := make([]*data.Candle, len(matching))
+	for i, candle := range matching {
+		copied := *candle
+		candles[i] = &copied
+	}
+	return candles
+}
+
+// PriceAt returns the price of a market at timestamp, based on the last 1m
+// candle that closed at or before it. Interpolated blends that close with
+// the close of the following candle, Strict fails when the last close is
+// more than maxStaleMinutes older than timestamp. A timestamp after now is
+// rejected, its price is not known yet.
+func (s *Store) PriceAt(marketId string, timestamp uint64, policy PricePolicy, maxStaleMinutes int, now time.Time) (*PriceQuote, error) {
+	if timestamp > uint64(now.UnixMilli()) {
+		return nil, fmt.Errorf("%w, %d is after %d", ErrFutureTimestamp, timestamp, now.UnixMilli())
+	}
+	from := uint64(0)
+	if lookback := uint64(PriceLookback.Milliseconds()); timestamp > lookback {
+		from = timestamp - lookback
+	}
+	before := s.closedCandles(marketId, from, timestamp, now)
+	if len(before) == 0 {
+		return nil, fmt.Errorf("%w for %s at %d", ErrNoPrice, marketId, timestamp)
+	}
+	previous := before[len(before)-1]
+	quote := &PriceQuote{
+		MarketId:  marketId,
+		Timestamp: timestamp,
+		Policy:    policy,
+		Price:     previous.Close,
+		Candles:   []*data.Candle{previous},
+	}
+	switch policy {
+	case Strict:
+		if maxStaleMinutes <= 0 {
+			maxStaleMinutes = DefaultMaxStaleMinutes
+		}
+		if timestamp-previous.ClosingTimestamp > uint64(maxStaleMinutes)*60000 {
+			return nil, fmt.Errorf("%w, last close for %s was at %d, more than %d minutes before %d", ErrStalePrice, marketId, previous.ClosingTimestamp, maxStaleMinutes, timestamp)
+		}
+	case Interpolated:
+		if previous.ClosingTimestamp == timestamp {
+			break
+		}
+		after := s.closedCandles(marketId, timestamp+1, timestamp+uint64(PriceLookback.Milliseconds()), now)
+		if len(after) == 0 {
+			return nil, fmt.Errorf("%w for %s at %d, no candle closed after it to interpolate with", ErrNoPrice, marketId, timestamp)
+		}
+		next := after[0]
+		weight := float64(timestamp-previous.ClosingTimestamp) / float64(next.ClosingTimestamp-previous.ClosingTimestamp)
+		quote.Price = previous.Close + (next.Close-previous.Close)*weight
+		quote.Candles = append(quote.Candles, next)
+	}
+	return quote, nil
+}
+
+// AveragePrice returns the time-weighted average close over [from, to),
+// weighting each 1m candle by how much of it falls inside the window, and
+// the volume-weighted average of the typical price (high+low+close)/3 when
+// the candles carry volume. A window ending after now is rejected.
+func (s *Store) AveragePrice(marketId string, from uint64, to uint64, now time.Time) (*AverageQuote, error) {
+	if to <= from {
+		return nil, fmt.Errorf("window end %d must be after its start %d", to, from)
+	}
+	if to > uint64(now.UnixMilli()) {
+		return nil, fmt.Errorf("%w, window end %d is after %d", ErrFutureTimestamp, to, now.UnixMilli())
+	}
+	candles := s.closedCandles(marketId, from+1, to+60000-1, now)
+	quote := &AverageQuote{MarketId: marketId, From: from, To: to, Candles: candles}
+	weighted, weights, volumeWeighted, volume := 0.0, 0.0, 0.0, 0.0
+	for _, candle := range candles {
+		overlap := min(candle.ClosingTimestamp, to) - max(candle.OpeningTimestamp, from)
+		weighted += candle.Close * float64(overlap)
+		weights += float64(overlap)
+		fraction := float64(overlap) / float64(candle.ClosingTimestamp-candle.OpeningTimestamp)
+		volumeWeighted += (candle.High + candle.Low + candle.Close) / 3 * candle.Volume * fraction
+		volume += candle.Volume * fraction
+	}
+	if weights == 0 {
+		return nil, fmt.Errorf("%w for %s between %d and %d", ErrNoPrice, marketId, from, to)
+	}
+	quote.Twap = weighted / weights
+	if volume > 0 {
+		vwap := volumeWeighted / volume
+		quote.Vwap = &vwap
+	}
+	return quote, nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStore_PriceAt(t *testing.T) {
+	btc := &Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT"}
+	s := NewStore(testIntervals, []*Config{btc}, provider.NewRegistry(), nil, nil)
+	base := uint64(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli())
+	now := time.UnixMilli(int64(base + 3600000))
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, base+60000, base, 100, 100, 100, 100, 1, 1))
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, base+600000, base+540000, 200, 200, 200, 200, 1, 1))
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, base+3660000, base+3600000, 300, 300, 300, 300, 1, 1))
+
+	quote, err := s.PriceAt("btc", base+300000, PreviousClose, 0, now)
+	if err != nil || quote.Price != 100 || len(quote.Candles) != 1 || quote.Candles[0].ClosingTimestamp != base+60000 {
+		t.Fatalf("unexpected previous close %+v %v", quote, err)
+	}
+	quote, err = s.PriceAt("btc", base+330000, Interpolated, 0, now)
+	if err != nil || quote.Price != 150 || len(quote.Candles) != 2 {
+		t.Fatalf("unexpected interpolated price %+v %v", quote, err)
+	}
+	if _, err = s.PriceAt("btc", base+360000, Strict, 4, now); !errors.Is(err, ErrStalePrice) {
+		t.Fatalf("expected a stale price, got %v", err)
+	}
+	if quote, err = s.PriceAt("btc", base+360000, Strict, 5, now); err != nil || quote.Price != 100 {
+		t.Fatalf("unexpected strict price %+v %v", quote, err)
+	}
+	if _, err = s.PriceAt("btc", base, PreviousClose, 0, now); !errors.Is(err, ErrNoPrice) {
+		t.Fatalf("expected no price before the first close, got %v", err)
+	}
+	if _, err = s.PriceAt("btc", base+1200000, Interpolated, 0, now); !errors.Is(err, ErrNoPrice) {
+		t.Fatalf("expected the forming candle not to be used, got %v", err)
+	}
+	if _, err = s.PriceAt("btc", base+3660000, PreviousClose, 0, now); !errors.Is(err, ErrFutureTimestamp) {
+		t.Fatalf("expected a timestamp after now to be rejected, got %v", err)
+	}
+}
+
+func TestStore_AveragePrice(t *testing.T) {
+	btc := &Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT"}
+	s := NewStore(testIntervals, []*Config{btc}, provider.NewRegistry(), nil, nil)
+	now := time.UnixMilli(3600000)
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 10, 10, 10, 10, 1, 10))
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 20, 20, 20, 20, 3, 60))
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 180000, 120000, 40, 40, 40, 40, 0, 0))
+
+	quote, err := s.AveragePrice("btc", 0, 120000, now)
+	if err != nil || quote.Twap != 15 || quote.Vwap == nil || *quote.Vwap != 17.5 || len(quote.Candles) != 2 {
+		t.Fatalf("unexpected averages %+v %v", quote, err)
+	}
+	quote, err = s.AveragePrice("btc", 90000, 150000, now)
+	if err != nil || quote.Twap != 30 || len(quote.Candles) != 2 {
+		t.Fatalf("expected partial candles to be weighted by overlap, got %+v %v", quote, err)
+	}
+	if quote, err = s.AveragePrice("btc", 120000, 180000, now); err != nil || quote.Vwap != nil {
+		t.Fatalf("expected no vwap without volume, got %+v %v", quote, err)
+	}
+	if _, err = s.AveragePrice("btc", 600000, 660000, now); !errors.Is(err, ErrNoPrice) {
+		t.Fatalf("expected no price, got %v", err)
+	}
+	if _, err = s.AveragePrice("btc", 0, 3600001, now); !errors.Is(err, ErrFutureTimestamp) {
+		t.Fatalf("expected a window ending after now to be rejected, got %v", err)
+	}
+}
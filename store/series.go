@@ -0,0 +1,81 @@
+package store
+
+import (
+	"candles-api/data"
+	"sort"
+)
+
+type series struct {
+	candles []*data.Candle
+}
+
+func (s *series) search(closingTimestamp uint64) int {
+	return sort.Search(len(s.candles), func(i int) bool {
+		return s.candles[i].ClosingTimestamp >= closingTimestamp
+	})
+}
+
+func (s *series) get(closingTimestamp uint64) *data.Candle {
+	i := s.search(closingTimestamp)
+	if i < len(s.candles) && s.candles[i].ClosingTimestamp == closingTimestamp {
+		return s.candles[i]
+	}
+	return nil
+}
+
+func (s *series) upsert(candle *data.Candle) bool {
+	n := len(s.candles)
+	if n == 0 || s.candles[n-1].ClosingTimestamp < candle.ClosingTimestamp {
+		s.candles = append(s.candles, candle)
+		return true
+	}
+	i := s.search(candle.ClosingTimestamp)
+	if s.candles[i].ClosingTimestamp == candle.ClosingTimestamp {
+		if s.candles[i].Equal(candle) {
+			return false
+		}
+		s.candles[i] = candle
+		return true
+	}
+	s.candles = append(s.candles, nil)
+	copy(s.candles[i+1:], s.candles[i:])
+	s.candles[i] = candle
+	return true
+}
+
+func (s *series) remove(closingTimestamp uint64) bool {
+	i := s.search(closingTimestamp)
+	if i == len(s.candles) || s.candles[i].ClosingTimestamp != closingTimestamp {
+		return false
+	}
+	copy(s.candles[i:], s.candles[i+1:])
+	s.candles[len(s.candles)-1] = nil
+	s.candles = s.candles[:len(s.candles)-1]
+	return true
+}
+
+// between returns the candles with fromTimestamp <= ClosingTimestamp <=
+// toTimestamp in ascending order, a zero toTimestamp means no upper bound.
+// The result shares the backing array and must not be modified.
+func (s *series) between(fromTimestamp uint64, toTimestamp uint64) []*data.Candle {
+	start := s.search(fromTimestamp)
+	end := len(s.candles)
+	if toTimestamp > 0 {
+		end = s.search(toTimestamp + 1)
+	}
+	if start >= end {
+		return nil
+	}
+	return s.candles[start:end]
+}
+
+func (s *series) trimBefore(closingTimestamp uint64) []*data.Candle {
+	i := s.search(closingTimestamp)
+	if i == 0 {
+		return nil
+	}
+	trimmed := make([]*data.Candle, i)
+	copy(trimmed, s.candles[:i])
+	s.candles = append(s.candles[:0:0], s.candles[i:]...)
+	return trimmed
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"candles-api/data"
+	"slices"
+	"testing"
+)
+
+func candleAt(ts uint64, price float64) *data.Candle {
+	return data.NewCandle("BTCUSDT", "btc", 60, ts, ts-60000, price, price, price, price, 0, 0)
+}
+
+func timestamps(candles []*data.Candle) []uint64 {
+	result := make([]uint64, 0, len(candles))
+	for _, candle := range candles {
+		result = append(result, candle.ClosingTimestamp)
+	}
+	return result
+}
+
+func TestSeries_UpsertKeepsOrder(t *testing.T) {
+	s := &series{}
+	for _, ts := range []uint64{300000, 120000, 600000, 180000, 120000} {
+		s.upsert(candleAt(ts, 1))
+	}
+	if got := timestamps(s.candles); !slices.Equal(got, []uint64{120000, 180000, 300000, 600000}) {
+		t.Fatalf("unexpected order %v", got)
+	}
+	if s.upsert(candleAt(180000, 1)) {
+		t.Fatal("expected identical candle to be reported unchanged")
+	}
+	if !s.upsert(candleAt(180000, 2)) || s.get(180000).Close != 2 {
+		t.Fatal("expected changed candle to replace the existing one")
+	}
+}
+
+func TestSeries_Between(t *testing.T) {
+	s := &series{}
+	for ts := uint64(60000); ts <= 600000; ts += 60000 {
+		s.upsert(candleAt(ts, 1))
+	}
+	cases := []struct {
+		from     uint64
+		to       uint64
+		expected []uint64
+	}{
+		{from: 120000, to: 240000, expected: []uint64{120000, 180000, 240000}},
+		{from: 130000, to: 230000, expected: []uint64{180000}},
+		{from: 540000, to: 0, expected: []uint64{540000, 600000}},
+		{from: 700000, to: 0, expected: []uint64{}},
+		{from: 240000, to: 120000, expected: []uint64{}},
+	}
+	for _, c := range cases {
+		if got := timestamps(s.between(c.from, c.to)); !slices.Equal(got, c.expected) {
+			t.Errorf("between(%d, %d): expected %v, got %v", c.from, c.to, c.expected, got)
+		}
+	}
+}
+
+func TestSeries_TrimAndRemove(t *testing.T) {
+	s := &series{}
+	for ts := uint64(60000); ts <= 300000; ts += 60000 {
+		s.upsert(candleAt(ts, 1))
+	}
+	if trimmed := timestamps(s.trimBefore(150000)); !slices.Equal(trimmed, []uint64{60000, 120000}) {
+		t.Fatalf("unexpected trimmed candles %v", trimmed)
+	}
+	if !s.remove(240000) || s.remove(240000) {
+		t.Fatal("expected remove to succeed exactly once")
+	}
+	if got := timestamps(s.candles); !slices.Equal(got, []uint64{180000, 300000}) {
+		t.Fatalf("unexpected remaining candles %v", got)
+	}
+}
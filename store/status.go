@@ -0,0 +1,78 @@
+package store
+
+import (
+	"candles-api/schedule"
+	"time"
+)
+
+type IntervalStatus struct {
+	Interval                 uint64 `json:"interval"`
+	Count                    int    `json:"count"`
+	EarliestClosingTimestamp uint64 `json:"earliestClosingTimestamp,omitempty"`
+	LatestClosingTimestamp   uint64 `json:"latestClosingTimestamp,omitempty"`
+}
+
+type MarketStatus struct {
+	MarketId      string            `json:"marketId"`
+	Source        PriceSource       `json:"source"`
+	Symbol        string            `json:"symbol"`
+	MicCode       string            `json:"micCode,omitempty"`
+	Schedule      string            `json:"schedule,omitempty"`
+	ActiveSources []string          `json:"activeSources"`
+	Intervals     []*IntervalStatus `json:"intervals"`
+	LastFetch     int64             `json:"lastFetch,omitempty"`
+	LastError     string            `json:"lastError,omitempty"`
+	LastErrorAt   int64             `json:"lastErrorAt,omitempty"`
+	Open          bool              `json:"open"`
+	Stale         bool              `json:"stale"`
+}
+
+func (s *Store) GetMarketStatus(config *Config, now time.Time) *MarketStatus {
+	status := &MarketStatus{
+		MarketId:      config.MarketId,
+		Source:        config.PriceSource,
+		Symbol:        config.Symbol,
+		MicCode:       config.MicCode,
+		Schedule:      config.Schedule,
+		ActiveSources: make([]string, 0),
+		Intervals:     make([]*IntervalStatus, 0),
+	}
+	for _, source := range s.activeSources(config) {
+		status.ActiveSources = append(status.ActiveSources, source.Key())
+	}
+	for _, interval := range s.Intervals() {
+		intervalStatus := &IntervalStatus{
+			Interval: interval.Seconds,
+			Count:    s.candles.Count(config.MarketId, interval.Seconds),
+		}
+		if earliest := s.candles.Earliest(config.MarketId, interval.Seconds, 1); len(earliest) > 0 {
+			intervalStatus.EarliestClosingTimestamp = earliest[0].ClosingTimestamp
+		}
+		if latest := s.candles.Latest(config.MarketId, interval.Seconds, 1); len(latest) > 0 {
+			intervalStatus.LatestClosingTimestamp = latest[0].ClosingTimestamp
+		}
+		status.Intervals = append(status.Intervals, intervalStatus)
+	}
+	fetch := s.lastFetch(config.MarketId)
+	if !fetch.lastFetch.IsZero() {
+		status.LastFetch = fetch.lastFetch.UnixMilli()
+	}
+	if len(fetch.lastError) > 0 {
+		status.LastError = fetch.lastError
+		status.LastErrorAt = fetch.lastErrorAt.UnixMilli()
+	}
+	if sched, err := schedule.Parse(config.Schedule); err == nil {
+		status.Open = sched.IsOpen(now)
+	}
+	newest := s.newestClosingTimestamp(config.MarketId)
+	status.Stale = newest == 0 || s.isStale(config, newest, now)
+	return status
+}
+
+func (s *Store) GetMarketStatuses(now time.Time) []*MarketStatus {
+	statuses := make([]*MarketStatus, 0)
+	for _, config := range s.Config() {
+		statuses = append(statuses, s.GetMarketStatus(config, now))
+	}
+	return statuses
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStore_GetMarketStatus(t *testing.T) {
+	now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	jo1 := &Config{MarketId: "jo1", PriceSource: "twelve-data", Symbol: "JO1", MicCode: "COMMODITY", Schedule: "24/7"}
+	s := NewStore(testIntervals, []*Config{jo1}, provider.NewRegistry(), nil, nil)
+	s.recordFetch(jo1, jo1.AllSources()[0], nil, now.Add(-time.Minute))
+	s.recordFetch(jo1, jo1.AllSources()[0], errors.New("rate limited"), now)
+	for _, ts := range []uint64{uint64(now.UnixMilli()) - 120000, uint64(now.UnixMilli()) - 60000} {
+		s.SaveCandle(data.NewCandle("JO1", "jo1", 60, ts, ts-60000, 1, 1, 1, 1, 0, 0))
+	}
+
+	status := s.GetMarketStatus(jo1, now)
+	if status.Source != "twelve-data" || status.MicCode != "COMMODITY" || len(status.ActiveSources) != 1 || status.ActiveSources[0] != "twelve-data:JO1" {
+		t.Fatalf("unexpected market %+v", status)
+	}
+	if status.LastFetch != now.Add(-time.Minute).UnixMilli() || status.LastError != "twelve-data:JO1: rate limited" || status.LastErrorAt != now.UnixMilli() {
+		t.Fatalf("unexpected fetch status %+v", status)
+	}
+	if !status.Open || status.Stale {
+		t.Fatalf("expected an open, fresh market, got %+v", status)
+	}
+	if len(status.Intervals) != len(testIntervals) {
+		t.Fatalf("expected every configured interval, got %+v", status.Intervals)
+	}
+	minute := status.Intervals[0]
+	if minute.Interval != 60 || minute.Count != 2 || minute.EarliestClosingTimestamp != uint64(now.UnixMilli())-120000 || minute.LatestClosingTimestamp != uint64(now.UnixMilli())-60000 {
+		t.Fatalf("unexpected 1m status %+v", minute)
+	}
+	if !s.GetMarketStatus(jo1, now.Add(time.Hour)).Stale {
+		t.Fatal("expected the market to go stale")
+	}
+}
@@ -1,13 +1,15 @@
 package store
 
 import (
-	"candles-api/bybit"
+	"candles-api/clock"
 	"candles-api/data"
-	"candles-api/polygon"
-	"candles-api/twelve_data"
+	"candles-api/journal"
+	"candles-api/metrics"
+	"candles-api/provider"
+	"context"
+	"fmt"
 	"github.com/charmbracelet/log"
-	"maps"
-	"sort"
+	"slices"
 	"sync"
 	"time"
 )
@@ -25,239 +27,581 @@ type Interval struct {
 	Retention time.Duration
 }
 
+type Source struct {
+	PriceSource  PriceSource
+	Symbol       string
+	MicCode      string
+	Weight       float64
+	MaxDeviation float64
+}
+
+func (s *Source) Key() string {
+	return fmt.Sprintf("%s:%s", s.PriceSource, s.Symbol)
+}
+
 type Config struct {
-	MarketId    string
-	PriceSource PriceSource
-	Symbol      string
-	MicCode     string
+	MarketId       string
+	PriceSource    PriceSource
+	Symbol         string
+	MicCode        string
+	Schedule       string
+	Sources        []*Source
+	MinSources     int
+	Fallbacks      []*Source
+	StaleAfter     time.Duration
+	MaxJumpPercent float64
+	MaxJumpStdDevs float64
+}
+
+func (c *Config) Equal(other *Config) bool {
+	return c.MarketId == other.MarketId &&
+		c.PriceSource == other.PriceSource &&
+		c.Symbol == other.Symbol &&
+		c.MicCode == other.MicCode &&
+		c.Schedule == other.Schedule &&
+		c.MinSources == other.MinSources &&
+		c.StaleAfter == other.StaleAfter &&
+		c.MaxJumpPercent == other.MaxJumpPercent &&
+		c.MaxJumpStdDevs == other.MaxJumpStdDevs &&
+		slices.EqualFunc(c.Sources, other.Sources, equalSource) &&
+		slices.EqualFunc(c.Fallbacks, other.Fallbacks, equalSource)
+}
+
+func equalSource(a *Source, b *Source) bool {
+	return *a == *b
+}
+
+func (c *Config) AllSources() []*Source {
+	if len(c.Sources) > 0 {
+		return c.Sources
+	}
+	return []*Source{{PriceSource: c.PriceSource, Symbol: c.Symbol, MicCode: c.MicCode, Weight: 1}}
+}
+
+func (c *Config) IsConsensus() bool {
+	return len(c.Sources) > 1
 }
 
+const (
+	RemovedMarketGracePeriod = time.Hour
+	SnapshotInterval         = time.Minute * 10
+)
+
 type Store struct {
-	candles          map[string]map[uint64]map[uint64]*data.Candle
-	intervals        []*Interval
-	config           []*Config
-	twelveDataClient *twelve_data.Client
-	polygonClient    *polygon.Client
-	bybitClient      *bybit.Client
-	candlesLock      sync.RWMutex
+	candles         Backend
+	intervals       []*Interval
+	config          []*Config
+	removed         map[string]time.Time
+	providers       *provider.Registry
+	journal         *journal.Journal
+	subscribers     map[subscriptionKey]map[*Subscription]bool
+	dirty           map[string]map[uint64]bool
+	gaps            *gapTracker
+	consensus       *consensusTracker
+	failover        *failoverTracker
+	quarantined     *quarantine
+	fetches         *fetchTracker
+	tickers         *tickerTracker
+	clock           clock.Clock
+	ctx             context.Context
+	cancel          context.CancelFunc
+	loops           sync.WaitGroup
+	candlesLock     sync.Mutex
+	configLock      sync.RWMutex
+	subscribersLock sync.Mutex
+	dirtyLock       sync.Mutex
 }
 
 func NewStore(
 	intervals []*Interval,
 	config []*Config,
-	twelveDataClient *twelve_data.Client,
-	polygonClient *polygon.Client,
-	bybitClient *bybit.Client,
+	providers *provider.Registry,
+	candleJournal *journal.Journal,
+	backend Backend,
 ) *Store {
-	return &Store{
-		intervals:        intervals,
-		config:           config,
-		twelveDataClient: twelveDataClient,
-		polygonClient:    polygonClient,
-		bybitClient:      bybitClient,
-		candles:          map[string]map[uint64]map[uint64]*data.Candle{},
+	if backend == nil {
+		backend = NewSeriesBackend()
+	}
+	s := &Store{
+		intervals:   intervals,
+		config:      config,
+		removed:     map[string]time.Time{},
+		providers:   providers,
+		journal:     candleJournal,
+		subscribers: map[subscriptionKey]map[*Subscription]bool{},
+		dirty:       map[string]map[uint64]bool{},
+		gaps:        &gapTracker{reports: map[string]*GapReport{}},
+		consensus:   &consensusTracker{staged: map[string]map[uint64]map[string]*data.Candle{}},
+		failover:    &failoverTracker{states: map[string]*failoverState{}, lastSeen: map[string]map[string]uint64{}},
+		quarantined: &quarantine{candles: map[string]map[uint64]*QuarantinedCandle{}},
+		fetches:     &fetchTracker{markets: map[string]*fetchStatus{}},
+		tickers:     &tickerTracker{windows: map[string]*tickerWindow{}},
+		candles:     backend,
+		clock:       clock.System(),
 	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.restore()
+	return s
 }
 
-func (s *Store) SaveCandle(candle *data.Candle) {
+func (s *Store) Now() time.Time {
+	return s.clock.Now()
+}
+
+// WithClock replaces the wall clock used for retention, aggregation and the
+// background loops. It should be set before any loop is started.
+func (s *Store) WithClock(c clock.Clock) *Store {
+	s.clock = c
+	return s
+}
+
+func (s *Store) restore() {
+	if s.journal == nil {
+		return
+	}
+	started := time.Now()
+	count, err := s.journal.Replay(func(op journal.Op, candle *data.Candle) {
+		if op == journal.Remove {
+			s.removeCandle(candle)
+		} else {
+			s.saveCandle(candle)
+		}
+	})
+	if err != nil {
+		log.Errorf("cannot replay journal %v", err)
+	}
+	log.Infof("restored %d journal entries in %s", count, time.Since(started))
+}
+
+func (s *Store) Config() []*Config {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.config
+}
+
+func (s *Store) Intervals() []*Interval {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.intervals
+}
+
+// Reload swaps in a new configuration. Markets that are new or changed, and
+// every market when an interval is added, have their stored 1m history
+// marked dirty so the next aggregation pass backfills the higher intervals.
+func (s *Store) Reload(intervals []*Interval, config []*Config) {
+	s.configLock.Lock()
+	previous := map[string]*Config{}
+	for _, c := range s.config {
+		previous[c.MarketId] = c
+	}
+	known := map[uint64]bool{}
+	for _, interval := range s.intervals {
+		known[interval.Seconds] = true
+	}
+	intervalAdded := false
+	for _, interval := range intervals {
+		if !known[interval.Seconds] {
+			log.Infof("interval %ds added", interval.Seconds)
+			intervalAdded = true
+		}
+	}
+	reaggregate := make([]string, 0)
+	for _, c := range config {
+		old, ok := previous[c.MarketId]
+		if !ok {
+			log.Infof("market %s (%s) added", c.MarketId, c.Symbol)
+		} else if !old.Equal(c) {
+			log.Infof("market %s (%s) changed", c.MarketId, c.Symbol)
+		}
+		if intervalAdded || !ok || !old.Equal(c) {
+			reaggregate = append(reaggregate, c.MarketId)
+		}
+		delete(previous, c.MarketId)
+		delete(s.removed, c.MarketId)
+	}
+	for marketId, c := range previous {
+		log.Infof("market %s (%s) removed, purging candles in %s", marketId, c.Symbol, RemovedMarketGracePeriod)
+		s.removed[marketId] = s.clock.Now()
+	}
+	s.intervals = intervals
+	s.config = config
+	s.configLock.Unlock()
+	for _, marketId := range reaggregate {
+		for _, candle := range s.GetCandles(marketId, 60, 1, 0) {
+			s.markDirty(candle)
+		}
+	}
+}
+
+func (s *Store) purgeRemovedMarkets() {
+	s.configLock.Lock()
+	expired := make([]string, 0)
+	for marketId, removedAt := range s.removed {
+		if s.clock.Now().Sub(removedAt) >= RemovedMarketGracePeriod {
+			expired = append(expired, marketId)
+			delete(s.removed, marketId)
+		}
+	}
+	s.configLock.Unlock()
+	if len(expired) == 0 {
+		return
+	}
+	s.lockCandles()
+	defer s.candlesLock.Unlock()
+	s.gaps.lock.Lock()
+	for _, marketId := range expired {
+		delete(s.gaps.reports, marketId)
+	}
+	s.gaps.lock.Unlock()
+	s.consensus.lock.Lock()
+	for _, marketId := range expired {
+		delete(s.consensus.staged, marketId)
+	}
+	s.consensus.lock.Unlock()
+	s.failover.lock.Lock()
+	for _, marketId := range expired {
+		delete(s.failover.states, marketId)
+		delete(s.failover.lastSeen, marketId)
+	}
+	s.failover.lock.Unlock()
+	s.quarantined.lock.Lock()
+	for _, marketId := range expired {
+		delete(s.quarantined.candles, marketId)
+	}
+	s.quarantined.lock.Unlock()
+	s.fetches.lock.Lock()
+	for _, marketId := range expired {
+		delete(s.fetches.markets, marketId)
+	}
+	s.fetches.lock.Unlock()
+	s.tickers.lock.Lock()
+	for _, marketId := range expired {
+		delete(s.tickers.windows, marketId)
+	}
+	s.tickers.lock.Unlock()
+	for _, marketId := range expired {
+		log.Infof("purging candles for removed market %s", marketId)
+		for _, candle := range s.candles.Purge(marketId) {
+			s.persist(journal.Remove, candle)
+		}
+	}
+}
+
+func (s *Store) lockCandles() {
+	started := time.Now()
 	s.candlesLock.Lock()
+	metrics.LockWait.Observe(metrics.Since(started))
+}
+
+func (s *Store) SaveCandle(candle *data.Candle) {
+	s.lockCandles()
 	defer s.candlesLock.Unlock()
-	if s.candles[candle.MarketId] == nil {
-		s.candles[candle.MarketId] = map[uint64]map[uint64]*data.Candle{}
+	if s.saveCandle(candle) {
+		s.persist(journal.Save, candle)
+		s.publish(candle)
 	}
-	if s.candles[candle.MarketId][candle.Interval] == nil {
-		s.candles[candle.MarketId][candle.Interval] = map[uint64]*data.Candle{}
+}
+
+func (s *Store) saveCandle(candle *data.Candle) bool {
+	if !s.candles.Save(candle) {
+		return false
 	}
-	s.candles[candle.MarketId][candle.Interval][candle.ClosingTimestamp] = candle
+	s.markDirty(candle)
+	s.updateTicker(candle, false, s.clock.Now())
+	return true
 }
 
 func (s *Store) RemoveCandle(candle *data.Candle) {
-	s.candlesLock.Lock()
+	s.lockCandles()
+	defer s.candlesLock.Unlock()
+	if s.removeCandle(candle) {
+		s.persist(journal.Remove, candle)
+	}
+}
+
+func (s *Store) removeCandle(candle *data.Candle) bool {
+	if !s.candles.Remove(candle.MarketId, candle.Interval, candle.ClosingTimestamp) {
+		return false
+	}
+	s.updateTicker(candle, true, s.clock.Now())
+	return true
+}
+
+func (s *Store) persist(op journal.Op, candle *data.Candle) {
+	if s.journal == nil {
+		return
+	}
+	err := s.journal.Append(op, candle)
+	if err != nil {
+		log.Errorf("cannot write candle %s to journal %v", candle.Id, err)
+	}
+}
+
+// every runs fn each interval on a goroutine until ctx is done or the store
+// is closed.
+func (s *Store) every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	s.spawn(ctx, func(ctx context.Context) {
+		s.clock.Every(ctx, interval, fn)
+	})
+}
+
+// spawn runs fn on a goroutine that Close waits for, with a context that is
+// cancelled when ctx is done or the store is closed.
+func (s *Store) spawn(ctx context.Context, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.ctx, cancel)
+	s.loops.Add(1)
+	go func() {
+		defer s.loops.Done()
+		defer cancel()
+		defer stop()
+		fn(ctx)
+	}()
+}
+
+// Close stops the background loops, waits for them to return and flushes
+// the journal. The journal itself is left open for its owner to close.
+func (s *Store) Close() error {
+	s.cancel()
+	s.loops.Wait()
+	if s.journal == nil {
+		return nil
+	}
+	s.lockCandles()
 	defer s.candlesLock.Unlock()
-	if s.candles[candle.MarketId] != nil {
-		if s.candles[candle.MarketId][candle.Interval] != nil {
-			delete(s.candles[candle.MarketId][candle.Interval], candle.ClosingTimestamp)
+	return s.journal.Flush()
+}
+
+func (s *Store) PersistCandles(ctx context.Context) {
+	if s.journal == nil {
+		return
+	}
+	s.every(ctx, time.Second, func(ctx context.Context) {
+		err := s.journal.Flush()
+		if err != nil {
+			log.Errorf("cannot flush journal %v", err)
 		}
+	})
+	s.every(ctx, SnapshotInterval, func(ctx context.Context) {
+		s.snapshot()
+	})
+}
+
+func (s *Store) snapshot() {
+	started := time.Now()
+	s.lockCandles()
+	candles := s.candles.All()
+	err := s.journal.Rotate()
+	s.candlesLock.Unlock()
+	if err != nil {
+		log.Errorf("cannot rotate journal %v", err)
+		return
 	}
+	err = s.journal.Snapshot(candles)
+	if err != nil {
+		log.Errorf("cannot write snapshot %v", err)
+		return
+	}
+	log.Infof("snapshot of %d candles took %s", len(candles), time.Since(started))
 }
 
 func (s *Store) GetCandles(marketId string, interval uint64, fromTimestamp uint64, toTimestamp uint64) []*data.Candle {
-	s.candlesLock.RLock()
-	defer s.candlesLock.RUnlock()
-	candles := make([]*data.Candle, 0)
-	if s.candles[marketId] != nil {
-		if s.candles[marketId][interval] != nil {
-			candlesList := maps.Values(s.candles[marketId][interval])
-			for candle := range candlesList {
-				if candle.ClosingTimestamp >= fromTimestamp && (candle.ClosingTimestamp <= toTimestamp || toTimestamp == 0) {
-					candles = append(candles, &data.Candle{
-						Id:               candle.Id,
-						Symbol:           candle.Symbol,
-						MarketId:         candle.MarketId,
-						Interval:         candle.Interval,
-						ClosingTimestamp: candle.ClosingTimestamp,
-						OpeningTimestamp: candle.OpeningTimestamp,
-						Open:             candle.Open,
-						Close:            candle.Close,
-						High:             candle.High,
-						Low:              candle.Low,
-						Volume:           candle.Volume,
-						Turnover:         candle.Turnover,
-					})
-				}
-			}
-		}
+	return descending(s.candles.Range(marketId, interval, fromTimestamp, toTimestamp))
+}
+
+func (s *Store) GetLatestCandles(marketId string, interval uint64, n int) []*data.Candle {
+	return descending(s.candles.Latest(marketId, interval, n))
+}
+
+func descending(matching []*data.Candle) []*data.Candle {
+	candles := make([]*data.Candle, len(matching))
+	for i, candle := range matching {
+		copied := *candle
+		candles[len(matching)-1-i] = &copied
 	}
-	sort.Slice(candles, func(i, j int) bool {
-		return candles[i].ClosingTimestamp > candles[j].ClosingTimestamp
-	})
 	return candles
 }
 
-func (s *Store) ArchiveCandles() {
-	go func() {
-		for range time.NewTicker(time.Second).C {
-			started := time.Now()
-			for _, interval := range s.intervals {
-				oldestTimestamp := time.Now().Add(-interval.Retention).UnixMilli()
-				for _, config := range s.config {
-					candles := s.GetCandles(config.MarketId, interval.Seconds, 1, 0)
-					for _, candle := range candles {
-						if int64(candle.ClosingTimestamp) < oldestTimestamp {
-							s.RemoveCandle(candle)
-						}
-					}
-				}
+func (s *Store) TrimCandles(marketId string, interval uint64, oldestTimestamp uint64) int {
+	s.lockCandles()
+	defer s.candlesLock.Unlock()
+	trimmed := s.candles.TrimBefore(marketId, interval, oldestTimestamp)
+	for _, candle := range trimmed {
+		s.updateTicker(candle, true, s.clock.Now())
+		s.persist(journal.Remove, candle)
+	}
+	return len(trimmed)
+}
+
+func (s *Store) ArchiveCandles(ctx context.Context) {
+	s.every(ctx, time.Second, func(ctx context.Context) {
+		started := time.Now()
+		s.purgeRemovedMarkets()
+		for _, interval := range s.Intervals() {
+			oldestTimestamp := s.clock.Now().Add(-interval.Retention).UnixMilli()
+			for _, config := range s.Config() {
+				s.TrimCandles(config.MarketId, interval.Seconds, uint64(oldestTimestamp))
 			}
-			ended := time.Now()
-			log.Infof("archive took %.5f milliseconds", float64(ended.UnixNano()-started.UnixNano())/1000000.0)
 		}
-	}()
+		metrics.PassDuration.WithLabelValues("archive").Observe(metrics.Since(started))
+	})
 }
 
 func (s *Store) GetStartingTimestampsForInterval(interval uint64, first uint64, last uint64) []uint64 {
-	firstMinute := time.UnixMilli(int64(first)).Minute()
-	lastMinute := time.UnixMilli(int64(last)).Minute()
-	remainderStart := firstMinute % int(interval/60)
-	remainderEnd := lastMinute % int(interval/60)
-	start := time.UnixMilli(int64(first)).Add(time.Minute * time.Duration(remainderStart*-1))
-	end := time.UnixMilli(int64(last))
-	if remainderEnd > 0 {
-		end = end.Add(time.Minute * time.Duration(interval/60))
-	}
 	results := make([]uint64, 0)
-	for ts := start.UnixMilli(); ts < end.UnixMilli(); ts += int64(interval) * 1000 {
-		results = append(results, uint64(time.UnixMilli(ts).UnixMilli()))
+	if last < first {
+		return results
+	}
+	for ts := bucketStart(first, interval); ts <= bucketStart(last, interval); ts += interval * 1000 {
+		results = append(results, ts)
 	}
 	return results
 }
 
-func (s *Store) AggregateCandles() {
-	go func() {
-		for range time.NewTicker(time.Second).C {
-			started := time.Now()
-			for _, config := range s.config {
-				candles := s.GetCandles(config.MarketId, 60, 1, 0)
-				if len(candles) == 0 {
-					continue
-				}
-				firstTs := candles[len(candles)-1].ClosingTimestamp
-				lastTs := candles[0].ClosingTimestamp
-				for _, interval := range s.intervals {
-					if interval.Seconds == 60 {
-						continue
-					}
-					fromTimestamps := s.GetStartingTimestampsForInterval(interval.Seconds, firstTs, lastTs)
-					intervalCandles := make([]*data.Candle, 0)
-					for _, ts := range fromTimestamps {
-						openingTimestamp := ts + 60000
-						closingTimestamp := ts + (interval.Seconds * 1000)
-						candles = s.GetCandles(config.MarketId, 60, openingTimestamp, closingTimestamp)
-						sort.Slice(candles, func(i, j int) bool {
-							return candles[i].ClosingTimestamp < candles[j].ClosingTimestamp
-						})
-						openPrice := 0.0
-						highPrice := 0.0
-						lowPrice := 9999999999.0
-						closePrice := 0.0
-						volume := 0.0
-						turnover := 0.0
-						for i, candle := range candles {
-							if i == 0 {
-								openPrice = candle.Open
-							}
-							if i == len(candles)-1 {
-								closePrice = candle.Close
-							}
-							if candle.High > highPrice {
-								highPrice = candle.High
-							}
-							if candle.Low < lowPrice {
-								lowPrice = candle.Low
-							}
-							volume += candle.Volume
-							turnover += candle.Turnover
-						}
-						if len(candles) > 0 {
-							intervalCandles = append(intervalCandles, data.NewCandle(
-								config.Symbol,
-								config.MarketId,
-								interval.Seconds,
-								closingTimestamp,
-								openingTimestamp,
-								openPrice,
-								closePrice,
-								highPrice,
-								lowPrice,
-								volume,
-								turnover,
-							))
-						}
-					}
-					for _, candle := range intervalCandles {
-						s.SaveCandle(candle)
-					}
+func bucketStart(closingTimestamp uint64, interval uint64) uint64 {
+	if closingTimestamp < 60000 {
+		return 0
+	}
+	ts := closingTimestamp - 60000
+	return ts - ts%(interval*1000)
+}
+
+func (s *Store) markDirty(candle *data.Candle) {
+	if candle.Interval != 60 {
+		return
+	}
+	s.dirtyLock.Lock()
+	defer s.dirtyLock.Unlock()
+	if s.dirty[candle.MarketId] == nil {
+		s.dirty[candle.MarketId] = map[uint64]bool{}
+	}
+	s.dirty[candle.MarketId][candle.ClosingTimestamp] = true
+}
+
+func (s *Store) takeDirty() map[string]map[uint64]bool {
+	s.dirtyLock.Lock()
+	defer s.dirtyLock.Unlock()
+	dirty := s.dirty
+	s.dirty = map[string]map[uint64]bool{}
+	return dirty
+}
+
+func (s *Store) AggregateCandles(ctx context.Context) {
+	s.every(ctx, time.Second, func(ctx context.Context) {
+		started := time.Now()
+		buckets := s.aggregate()
+		metrics.PassDuration.WithLabelValues("aggregate").Observe(metrics.Since(started))
+		if buckets > 0 {
+			log.Debugf("aggregation of %d buckets took %s", buckets, time.Since(started))
+		}
+	})
+}
+
+func (s *Store) aggregate() int {
+	dirty := s.takeDirty()
+	intervals := s.Intervals()
+	count := 0
+	for _, config := range s.Config() {
+		timestamps := dirty[config.MarketId]
+		if len(timestamps) == 0 {
+			continue
+		}
+		for _, interval := range intervals {
+			if interval.Seconds == 60 {
+				continue
+			}
+			buckets := map[uint64]bool{}
+			for ts := range timestamps {
+				buckets[bucketStart(ts, interval.Seconds)] = true
+			}
+			for ts := range buckets {
+				candle := s.aggregateBucket(config, interval.Seconds, ts)
+				if candle != nil {
+					s.SaveCandle(candle)
 				}
 			}
-			ended := time.Now()
-			log.Infof("aggregation took %.5f milliseconds", float64(ended.UnixNano()-started.UnixNano())/1000000.0)
+			count += len(buckets)
 		}
-	}()
+	}
+	return count
 }
 
-func (s *Store) SyncCandles() {
-	go func() {
-		for range time.NewTicker(time.Second).C {
-			for _, config := range s.config {
-				go func() {
-					candles := make([]*data.Candle, 0)
-					if config.PriceSource == Bybit {
-						candles = s.bybitClient.GetLatestCandles(config.Symbol)
-					} else if config.PriceSource == Polygon {
-						candles = s.polygonClient.GetLatestCandles(config.Symbol)
-					}
-					for _, candle := range candles {
-						candle.MarketId = config.MarketId
-						s.SaveCandle(candle)
-					}
-				}()
-			}
+func (s *Store) aggregateBucket(config *Config, interval uint64, ts uint64) *data.Candle {
+	openingTimestamp := ts
+	closingTimestamp := ts + (interval * 1000)
+	candles := s.GetCandles(config.MarketId, 60, openingTimestamp+60000, closingTimestamp)
+	if len(candles) == 0 {
+		return nil
+	}
+	slices.Reverse(candles)
+	openPrice := 0.0
+	highPrice := 0.0
+	lowPrice := 9999999999.0
+	closePrice := 0.0
+	volume := 0.0
+	turnover := 0.0
+	for i, candle := range candles {
+		if i == 0 {
+			openPrice = candle.Open
 		}
-	}()
-	go func() {
-		for range time.NewTicker(time.Second * 15).C {
-			for _, config := range s.config {
-				go func() {
-					candles := make([]*data.Candle, 0)
-					if config.PriceSource == TwelveData {
-						candles = s.twelveDataClient.GetLatestCandles(config.Symbol, config.MicCode)
-					}
-					for _, candle := range candles {
-						candle.MarketId = config.MarketId
-						s.SaveCandle(candle)
+		if i == len(candles)-1 {
+			closePrice = candle.Close
+		}
+		if candle.High > highPrice {
+			highPrice = candle.High
+		}
+		if candle.Low < lowPrice {
+			lowPrice = candle.Low
+		}
+		volume += candle.Volume
+		turnover += candle.Turnover
+	}
+	return data.NewCandle(
+		config.Symbol,
+		config.MarketId,
+		interval,
+		closingTimestamp,
+		openingTimestamp,
+		openPrice,
+		closePrice,
+		highPrice,
+		lowPrice,
+		volume,
+		turnover,
+	)
+}
+
+func (s *Store) SyncCandles(ctx context.Context) {
+	for _, p := range s.providers.All() {
+		if streamer, ok := p.(provider.Streamer); ok {
+			s.spawn(ctx, func(ctx context.Context) {
+				s.streamCandles(ctx, p, streamer)
+			})
+			continue
+		}
+		s.every(ctx, p.PollInterval(), func(ctx context.Context) {
+			for _, config := range s.Config() {
+				for _, source := range s.pollSources(config) {
+					if string(source.PriceSource) != p.Name() {
+						continue
 					}
-				}()
+					s.spawn(ctx, func(ctx context.Context) {
+						s.syncSource(ctx, p, config, source)
+					})
+				}
 			}
-		}
-	}()
+		})
+	}
+}
+
+func (s *Store) syncSource(ctx context.Context, p provider.Provider, config *Config, source *Source) {
+	candles, err := p.GetLatestCandles(ctx, source.Symbol, source.MicCode)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Errorf("cannot sync %s from %s: %v", config.MarketId, source.Key(), err)
+	}
+	s.recordFetch(config, source, err, s.clock.Now())
+	s.receive(config, source, candles)
 }
@@ -0,0 +1,264 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"context"
+	"testing"
+	"time"
+)
+
+var testIntervals = []*Interval{
+	{Seconds: 60, Retention: time.Hour * 24},
+	{Seconds: 300, Retention: time.Hour * 24},
+}
+
+func TestStore_Reload(t *testing.T) {
+	btc := &Config{MarketId: "btc", PriceSource: Bybit, Symbol: "BTCUSDT"}
+	eth := &Config{MarketId: "eth", PriceSource: Bybit, Symbol: "ETHUSDT"}
+	s := NewStore(testIntervals, []*Config{btc, eth}, provider.NewRegistry(), nil, nil)
+	s.SaveCandle(data.NewCandle("ETHUSDT", "eth", 60, 60000, 0, 1, 1, 1, 1, 0, 0))
+
+	s.Reload(testIntervals, []*Config{btc})
+	if len(s.Config()) != 1 || s.Config()[0].MarketId != "btc" {
+		t.Fatalf("unexpected config %+v", s.Config())
+	}
+	if _, ok := s.removed["eth"]; !ok {
+		t.Fatal("expected eth to be scheduled for purge")
+	}
+	if len(s.GetCandles("eth", 60, 1, 0)) != 1 {
+		t.Fatal("expected eth candles to survive the grace period")
+	}
+
+	s.removed["eth"] = time.Now().Add(-RemovedMarketGracePeriod)
+	s.purgeRemovedMarkets()
+	if len(s.GetCandles("eth", 60, 1, 0)) != 0 {
+		t.Fatal("expected eth candles to be purged")
+	}
+
+	s.Reload(testIntervals, []*Config{btc, eth})
+	if _, ok := s.removed["eth"]; ok {
+		t.Fatal("expected re-added eth to no longer be scheduled for purge")
+	}
+}
+
+func TestStore_ReloadAggregatesHistory(t *testing.T) {
+	btc := &Config{MarketId: "btc", PriceSource: Bybit, Symbol: "BTCUSDT"}
+	s := NewStore(testIntervals, []*Config{btc}, provider.NewRegistry(), nil, nil)
+	for i := uint64(1); i <= 30; i++ {
+		s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, i*60000, (i-1)*60000, 1, 1, 1, 1, 1, 1))
+	}
+	s.aggregate()
+	if buckets := s.aggregate(); buckets != 0 {
+		t.Fatalf("expected no dirty buckets, got %d", buckets)
+	}
+
+	intervals := append(append([]*Interval{}, testIntervals...), &Interval{Seconds: 900, Retention: time.Hour * 24})
+	s.Reload(intervals, []*Config{btc})
+	s.aggregate()
+	if candles := s.GetCandles("btc", 900, 1, 0); len(candles) != 2 || candles[0].ClosingTimestamp != 1800000 || candles[0].Volume != 15 {
+		t.Fatalf("expected the added interval to cover stored history, got %+v", candles)
+	}
+
+	eth := &Config{MarketId: "eth", PriceSource: Bybit, Symbol: "ETHUSDT"}
+	s.SaveCandle(data.NewCandle("ETHUSDT", "eth", 60, 60000, 0, 1, 1, 1, 1, 1, 1))
+	s.takeDirty()
+	s.Reload(intervals, []*Config{btc, eth})
+	s.aggregate()
+	if candles := s.GetCandles("eth", 300, 1, 0); len(candles) != 1 {
+		t.Fatalf("expected the added market to cover stored history, got %+v", candles)
+	}
+	s.Reload(intervals, []*Config{btc, eth})
+	if buckets := s.aggregate(); buckets != 0 {
+		t.Fatalf("expected unchanged markets not to be re-aggregated, got %d", buckets)
+	}
+}
+
+func TestStore_Subscribe(t *testing.T) {
+	s := NewStore(testIntervals, []*Config{}, provider.NewRegistry(), nil, nil)
+	sub := s.Subscribe("btc", 60)
+	defer sub.Close()
+
+	first := data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 1, 1, 1, 1, 0, 0)
+	s.SaveCandle(first)
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 300, 300000, 0, 1, 1, 1, 1, 0, 0))
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 1, 1, 1, 1, 0, 0))
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 60000, 0, 1, 2, 2, 1, 0, 0))
+
+	received := make([]*data.Candle, 0)
+	for len(sub.C) > 0 {
+		received = append(received, <-sub.C)
+	}
+	if len(received) != 2 || received[0].Close != 1 || received[1].Close != 2 {
+		t.Fatalf("expected the original and the changed candle, got %+v", received)
+	}
+}
+
+func TestStore_SubscribeDropsSlowConsumer(t *testing.T) {
+	s := NewStore(testIntervals, []*Config{}, provider.NewRegistry(), nil, nil)
+	sub := s.Subscribe("btc", 60)
+	for i := uint64(1); i <= SubscriptionBuffer+1; i++ {
+		s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, i*60000, 0, 1, 1, 1, 1, 0, 0))
+	}
+	count := 0
+	for range sub.C {
+		count++
+	}
+	if count != SubscriptionBuffer {
+		t.Fatalf("expected %d buffered candles before the drop, got %d", SubscriptionBuffer, count)
+	}
+	sub.Close()
+}
+
+func TestStore_AggregateOnlyDirtyBuckets(t *testing.T) {
+	btc := &Config{MarketId: "btc", PriceSource: Bybit, Symbol: "BTCUSDT"}
+	s := NewStore(testIntervals, []*Config{btc}, provider.NewRegistry(), nil, nil)
+	for i := uint64(1); i <= 10; i++ {
+		s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, i*60000, (i-1)*60000, float64(i), float64(i)+0.5, float64(i)+1, float64(i)-1, 1, 2))
+	}
+	if buckets := s.aggregate(); buckets != 2 {
+		t.Fatalf("expected 2 dirty buckets, got %d", buckets)
+	}
+	candles := s.GetCandles("btc", 300, 1, 0)
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 aggregated candles, got %+v", candles)
+	}
+	first := candles[1]
+	if first.ClosingTimestamp != 300000 || first.OpeningTimestamp != 0 || first.Open != 1 || first.Close != 5.5 || first.High != 6 || first.Low != 0 || first.Volume != 5 || first.Turnover != 10 {
+		t.Fatalf("unexpected aggregated candle %+v", first)
+	}
+
+	if buckets := s.aggregate(); buckets != 0 {
+		t.Fatalf("expected no dirty buckets, got %d", buckets)
+	}
+	s.SaveCandle(data.NewCandle("BTCUSDT", "btc", 60, 600000, 540000, 10, 20, 21, 9, 1, 2))
+	if buckets := s.aggregate(); buckets != 1 {
+		t.Fatalf("expected 1 dirty bucket, got %d", buckets)
+	}
+	if latest := s.GetCandles("btc", 300, 600000, 600000); len(latest) != 1 || latest[0].Close != 20 || latest[0].High != 21 {
+		t.Fatalf("unexpected re-aggregated candle %+v", latest)
+	}
+}
+
+type fakeProvider struct {
+	name    string
+	candles map[uint64]*data.Candle
+	calls   int
+	syncing chan struct{}
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Intervals() []uint64 {
+	return []uint64{60}
+}
+
+func (p *fakeProvider) PollInterval() time.Duration {
+	return time.Second
+}
+
+func (p *fakeProvider) GetLatestCandles(ctx context.Context, _ string, _ string) ([]*data.Candle, error) {
+	if p.syncing == nil {
+		return nil, nil
+	}
+	select {
+	case p.syncing <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *fakeProvider) GetCandles(_ context.Context, symbol string, _ string, interval uint64, from time.Time, to time.Time) ([]*data.Candle, error) {
+	p.calls++
+	candles := make([]*data.Candle, 0)
+	for ts := uint64(from.UnixMilli()); ts <= uint64(to.UnixMilli()); ts += 60000 {
+		if candle, ok := p.candles[ts]; ok {
+			copied := *candle
+			candles = append(candles, &copied)
+		}
+	}
+	return candles, nil
+}
+
+func TestStore_RepairGaps(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+	btc := &Config{MarketId: "btc", PriceSource: "fake", Symbol: "BTCUSDT", Schedule: "24/7"}
+	fake := &fakeProvider{name: "fake", candles: map[uint64]*data.Candle{}}
+	s := NewStore(testIntervals, []*Config{btc}, provider.NewRegistry(fake), nil, nil)
+	first := now.Add(-GapLookback).Truncate(time.Minute)
+	repairable := uint64(now.Add(-time.Minute * 30).Truncate(time.Minute).UnixMilli())
+	missing := uint64(now.Add(-time.Minute * 20).Truncate(time.Minute).UnixMilli())
+	for minute := first; minute.Before(now.Add(-GapSettleTime)); minute = minute.Add(time.Minute) {
+		ts := uint64(minute.UnixMilli())
+		candle := data.NewCandle("BTCUSDT", "btc", 60, ts+60000, ts, 1, 1, 1, 1, 0, 0)
+		if ts == repairable || ts == repairable+60000 {
+			fake.candles[ts] = candle
+			continue
+		}
+		if ts == missing {
+			continue
+		}
+		s.SaveCandle(candle)
+	}
+
+	gaps := s.FindGaps(btc, now)
+	if len(gaps) != 2 || gaps[0].From != repairable || gaps[0].Minutes != 2 || gaps[1].From != missing || gaps[1].To != missing+60000 {
+		t.Fatalf("unexpected gaps %+v", gaps)
+	}
+
+	s.repairGaps(context.Background(), btc, now)
+	report := s.GetGaps("btc")
+	if len(report.Repaired) != 1 || report.Repaired[0].From != repairable {
+		t.Fatalf("expected the first gap to be repaired, got %+v", report.Repaired)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].From != missing || report.Missing[0].Attempts != 1 {
+		t.Fatalf("expected the second gap to remain, got %+v", report.Missing)
+	}
+	if len(s.GetCandles("btc", 60, repairable+60000, repairable+120000)) != 2 {
+		t.Fatal("expected the repaired candles to be stored")
+	}
+
+	for i := 1; i < MaxRepairAttempts; i++ {
+		s.repairGaps(context.Background(), btc, now)
+	}
+	report = s.GetGaps("btc")
+	if len(report.Missing) != 0 || len(report.Unrepairable) != 1 || report.Unrepairable[0].Attempts != MaxRepairAttempts {
+		t.Fatalf("expected the second gap to be given up on, got %+v", report)
+	}
+	calls := fake.calls
+	s.repairGaps(context.Background(), btc, now)
+	if fake.calls != calls {
+		t.Fatal("expected unrepairable gaps not to be retried")
+	}
+}
+
+func TestStore_Close(t *testing.T) {
+	btc := &Config{MarketId: "btc", PriceSource: "fake", Symbol: "BTCUSDT", Schedule: "24/7"}
+	fake := &fakeProvider{name: "fake", syncing: make(chan struct{}, 1)}
+	s := NewStore(testIntervals, []*Config{btc}, provider.NewRegistry(fake), nil, nil)
+	s.SyncCandles(context.Background())
+	s.AggregateCandles(context.Background())
+	select {
+	case <-fake.syncing:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected a sync to start")
+	}
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected close to cancel the in-flight sync and return")
+	}
+	if fetch := s.lastFetch("btc"); len(fetch.lastError) > 0 || !fetch.lastFetch.IsZero() {
+		t.Fatal("expected a cancelled sync not to be recorded as a fetch")
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"context"
+	"github.com/charmbracelet/log"
+	"time"
+)
+
+type marketStream struct {
+	config *Config
+	source *Source
+	cancel context.CancelFunc
+}
+
+func (s *Store) streamCandles(ctx context.Context, p provider.Provider, streamer provider.Streamer) {
+	streams := map[string]*marketStream{}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			for _, stream := range streams {
+				stream.cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+		active := map[string]bool{}
+		for _, config := range s.Config() {
+			for _, source := range s.pollSources(config) {
+				if string(source.PriceSource) != p.Name() {
+					continue
+				}
+				key := config.MarketId + "/" + source.Key()
+				active[key] = true
+				existing, ok := streams[key]
+				if ok && existing.config.Equal(config) {
+					continue
+				}
+				if ok {
+					existing.cancel()
+				}
+				streamCtx, cancel := context.WithCancel(ctx)
+				streams[key] = &marketStream{config: config, source: source, cancel: cancel}
+				s.spawn(streamCtx, func(ctx context.Context) {
+					err := streamer.Stream(ctx, source.Symbol, func(candle *data.Candle) {
+						s.recordFetch(config, source, nil, s.clock.Now())
+						s.receive(config, source, []*data.Candle{candle})
+					}, func() {
+						log.Infof("%s stream for %s connected, repairing gaps over rest", p.Name(), source.Symbol)
+						s.spawn(ctx, func(ctx context.Context) {
+							s.syncSource(ctx, p, config, source)
+						})
+					})
+					log.Infof("%s stream for %s stopped: %v", p.Name(), source.Symbol, err)
+				})
+			}
+		}
+		for key, stream := range streams {
+			if !active[key] {
+				stream.cancel()
+				delete(streams, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"candles-api/data"
+	"sync"
+)
+
+const SubscriptionBuffer = 256
+
+type Subscription struct {
+	MarketId string
+	Interval uint64
+	C        chan *data.Candle
+	store    *Store
+	once     sync.Once
+}
+
+func (s *Store) Subscribe(marketId string, interval uint64) *Subscription {
+	sub := &Subscription{
+		MarketId: marketId,
+		Interval: interval,
+		C:        make(chan *data.Candle, SubscriptionBuffer),
+		store:    s,
+	}
+	s.subscribersLock.Lock()
+	defer s.subscribersLock.Unlock()
+	key := subscriptionKey{marketId: marketId, interval: interval}
+	if s.subscribers[key] == nil {
+		s.subscribers[key] = map[*Subscription]bool{}
+	}
+	s.subscribers[key][sub] = true
+	return sub
+}
+
+func (sub *Subscription) Close() {
+	sub.store.subscribersLock.Lock()
+	defer sub.store.subscribersLock.Unlock()
+	sub.close()
+}
+
+func (sub *Subscription) close() {
+	sub.once.Do(func() {
+		key := subscriptionKey{marketId: sub.MarketId, interval: sub.Interval}
+		delete(sub.store.subscribers[key], sub)
+		if len(sub.store.subscribers[key]) == 0 {
+			delete(sub.store.subscribers, key)
+		}
+		close(sub.C)
+	})
+}
+
+type subscriptionKey struct {
+	marketId string
+	interval uint64
+}
+
+func (s *Store) publish(candle *data.Candle) {
+	s.subscribersLock.Lock()
+	defer s.subscribersLock.Unlock()
+	for sub := range s.subscribers[subscriptionKey{marketId: candle.MarketId, interval: candle.Interval}] {
+		update := *candle
+		select {
+		case sub.C <- &update:
+		default:
+			// a consumer that cannot keep up is dropped rather than silently
+			// skipping updates, it has to resubscribe and take a new snapshot
+			sub.close()
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"candles-api/data"
+	"sync"
+	"time"
+)
+
+const TickerWindow = time.Hour * 24
+
+type Ticker struct {
+	MarketId             string  `json:"marketId"`
+	Symbol               string  `json:"symbol"`
+	LastPrice            float64 `json:"lastPrice"`
+	LastClosingTimestamp uint64  `json:"lastClosingTimestamp"`
+	OpenPrice            float64 `json:"openPrice"`
+	PriceChange          float64 `json:"priceChange"`
+	PriceChangePercent   float64 `json:"priceChangePercent"`
+	High                 float64 `json:"high"`
+	Low                  float64 `json:"low"`
+	Volume               float64 `json:"volume"`
+	Turnover             float64 `json:"turnover"`
+}
+
+// tickerWindow keeps the 1m candles of the last 24h of a market together
+// with running totals, so reading a ticker does not scan the window unless
+// the candle holding the high or low has been replaced or has expired. The
+// totals are recomputed on that scan as well, which bounds rounding drift.
+type tickerWindow struct {
+	series
+	volume   float64
+	turnover float64
+	high     float64
+	low      float64
+	dirty    bool
+}
+
+type tickerTracker struct {
+	windows map[string]*tickerWindow
+	lock    sync.Mutex
+}
+
+func (w *tickerWindow) add(candle *data.Candle) {
+	if old := w.get(candle.ClosingTimestamp); old != nil {
+		w.drop(old)
+	}
+	w.upsert(candle)
+	w.volume += candle.Volume
+	w.turnover += candle.Turnover
+	if !w.dirty {
+		if len(w.candles) == 1 {
+			w.high, w.low = candle.High, candle.Low
+		} else {
+			w.high, w.low = max(w.high, candle.High), min(w.low, candle.Low)
+		}
+	}
+}
+
+func (w *tickerWindow) drop(candle *data.Candle) {
+	w.volume -= candle.Volume
+	w.turnover -= candle.Turnover
+	if candle.High >= w.high || candle.Low <= w.low {
+		w.dirty = true
+	}
+}
+
+func (w *tickerWindow) trim(closingTimestamp uint64) {
+	for _, candle := range w.trimBefore(closingTimestamp) {
+		w.drop(candle)
+	}
+}
+
+func (w *tickerWindow) extremes() (float64, float64) {
+	if w.dirty {
+		w.high, w.low, w.volume, w.turnover = 0, 0, 0, 0
+		for i, candle := range w.candles {
+			w.volume += candle.Volume
+			w.turnover += candle.Turnover
+			if i == 0 {
+				w.high, w.low = candle.High, candle.Low
+			} else {
+				w.high, w.low = max(w.high, candle.High), min(w.low, candle.Low)
+			}
+		}
+		w.dirty = false
+	}
+	return w.high, w.low
+}
+
+func windowStart(now time.Time) uint64 {
+	return uint64(now.Add(-TickerWindow).UnixMilli()) + 1
+}
+
+func (s *Store) updateTicker(candle *data.Candle, removed bool, now time.Time) {
+	if candle.Interval != 60 {
+		return
+	}
+	start := windowStart(now)
+	s.tickers.lock.Lock()
+	defer s.tickers.lock.Unlock()
+	w := s.tickers.windows[candle.MarketId]
+	if w == nil {
+		if removed || candle.ClosingTimestamp < start {
+			return
+		}
+		w = &tickerWindow{}
+		s.tickers.windows[candle.MarketId] = w
+	}
+	if removed {
+		if old := w.get(candle.ClosingTimestamp); old != nil {
+			w.drop(old)
+			w.remove(candle.ClosingTimestamp)
+		}
+	} else if candle.ClosingTimestamp >= start {
+		w.add(candle)
+	}
+	w.trim(start)
+}
+
+func (s *Store) GetTicker(config *Config, now time.Time) *Ticker {
+	ticker := &Ticker{MarketId: config.MarketId, Symbol: config.Symbol}
+	s.tickers.lock.Lock()
+	w := s.tickers.windows[config.MarketId]
+	if w != nil {
+		w.trim(windowStart(now))
+	}
+	if w != nil && len(w.candles) > 0 {
+		first, last := w.candles[0], w.candles[len(w.candles)-1]
+		ticker.LastPrice = last.Close
+		ticker.LastClosingTimestamp = last.ClosingTimestamp
+		ticker.OpenPrice = first.Open
+		ticker.High, ticker.Low = w.extremes()
+		ticker.Volume = w.volume
+		ticker.Turnover = w.turnover
+	}
+	s.tickers.lock.Unlock()
+	if ticker.LastClosingTimestamp == 0 {
+		if latest := s.candles.Latest(config.MarketId, 60, 1); len(latest) > 0 {
+			ticker.LastPrice = latest[0].Close
+			ticker.LastClosingTimestamp = latest[0].ClosingTimestamp
+			ticker.OpenPrice = latest[0].Close
+		}
+		return ticker
+	}
+	ticker.PriceChange = ticker.LastPrice - ticker.OpenPrice
+	if ticker.OpenPrice != 0 {
+		ticker.PriceChangePercent = ticker.PriceChange / ticker.OpenPrice * 100
+	}
+	return ticker
+}
+
+func (s *Store) GetTickers(now time.Time) []*Ticker {
+	tickers := make([]*Ticker, 0)
+	for _, config := range s.Config() {
+		tickers = append(tickers, s.GetTicker(config, now))
+	}
+	return tickers
+}
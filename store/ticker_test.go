@@ -0,0 +1,51 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"testing"
+	"time"
+)
+
+func TestStore_Ticker(t *testing.T) {
+	btc := &Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT"}
+	s := NewStore(testIntervals, []*Config{btc}, provider.NewRegistry(), nil, nil)
+	now := time.Now().Truncate(time.Minute)
+	at := func(ago time.Duration, open float64, close float64, high float64, low float64) *data.Candle {
+		ts := uint64(now.Add(-ago).UnixMilli())
+		return data.NewCandle("BTCUSDT", "btc", 60, ts, ts-60000, open, close, high, low, 2, 20)
+	}
+
+	if ticker := s.GetTicker(btc, now); ticker.LastClosingTimestamp != 0 || ticker.Volume != 0 {
+		t.Fatalf("expected an empty ticker, got %+v", ticker)
+	}
+
+	s.SaveCandle(at(time.Hour*25, 50, 50, 500, 5))
+	s.SaveCandle(at(time.Hour*23, 100, 101, 150, 95))
+	s.SaveCandle(at(time.Hour*12, 101, 110, 112, 90))
+	s.SaveCandle(at(time.Minute, 110, 120, 121, 109))
+	ticker := s.GetTicker(btc, now)
+	if ticker.LastPrice != 120 || ticker.OpenPrice != 100 || ticker.PriceChange != 20 || ticker.PriceChangePercent != 20 {
+		t.Fatalf("unexpected prices %+v", ticker)
+	}
+	if ticker.High != 150 || ticker.Low != 90 || ticker.Volume != 6 || ticker.Turnover != 60 {
+		t.Fatalf("unexpected 24h stats %+v", ticker)
+	}
+
+	s.SaveCandle(at(time.Hour*23, 100, 101, 130, 95))
+	if ticker = s.GetTicker(btc, now); ticker.High != 130 || ticker.Volume != 6 {
+		t.Fatalf("expected the replaced high to be recomputed, got %+v", ticker)
+	}
+
+	s.RemoveCandle(at(time.Hour*12, 101, 110, 112, 90))
+	if ticker = s.GetTicker(btc, now); ticker.Low != 95 || ticker.Volume != 4 {
+		t.Fatalf("expected the removed low to be recomputed, got %+v", ticker)
+	}
+
+	if ticker = s.GetTicker(btc, now.Add(time.Hour*2)); ticker.OpenPrice != 110 || ticker.High != 121 || ticker.Volume != 2 {
+		t.Fatalf("expected expired candles to leave the window, got %+v", ticker)
+	}
+	if ticker = s.GetTicker(btc, now.Add(time.Hour*48)); ticker.LastPrice != 120 || ticker.Volume != 0 || ticker.PriceChange != 0 {
+		t.Fatalf("expected only the last price without recent candles, got %+v", ticker)
+	}
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"candles-api/data"
+	"fmt"
+	"github.com/charmbracelet/log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxJumpStdDevs = 10.0
+	OutlierWindow         = time.Hour * 2
+	OutlierMinHistory     = 30
+	OutlierConfirmations  = 3
+	QuarantineHistory     = 500
+)
+
+type QuarantinedCandle struct {
+	Candle        *data.Candle `json:"candle"`
+	Source        string       `json:"source"`
+	Reason        string       `json:"reason"`
+	QuarantinedAt int64        `json:"quarantinedAt"`
+}
+
+type quarantine struct {
+	candles map[string]map[uint64]*QuarantinedCandle
+	lock    sync.Mutex
+}
+
+func ValidateCandle(candle *data.Candle) error {
+	prices := []float64{candle.Open, candle.High, candle.Low, candle.Close}
+	for _, price := range prices {
+		if math.IsNaN(price) || math.IsInf(price, 0) || price <= 0 {
+			return fmt.Errorf("invalid price %f", price)
+		}
+	}
+	if math.IsNaN(candle.Volume) || math.IsInf(candle.Volume, 0) || candle.Volume < 0 ||
+		math.IsNaN(candle.Turnover) || math.IsInf(candle.Turnover, 0) || candle.Turnover < 0 {
+		return fmt.Errorf("invalid volume %f or turnover %f", candle.Volume, candle.Turnover)
+	}
+	if candle.High < candle.Low {
+		return fmt.Errorf("high %f below low %f", candle.High, candle.Low)
+	}
+	if candle.High < max(candle.Open, candle.Close) || candle.Low > min(candle.Open, candle.Close) {
+		return fmt.Errorf("open %f or close %f outside high %f and low %f", candle.Open, candle.Close, candle.High, candle.Low)
+	}
+	if candle.Interval == 0 || candle.ClosingTimestamp != candle.OpeningTimestamp+candle.Interval*1000 {
+		return fmt.Errorf("invalid bounds %d-%d for interval %d", candle.OpeningTimestamp, candle.ClosingTimestamp, candle.Interval)
+	}
+	return nil
+}
+
+// jump is the largest move of any price in the candle relative to the
+// previous close, as a fraction of that close.
+func jump(previousClose float64, candle *data.Candle) float64 {
+	largest := 0.0
+	for _, price := range []float64{candle.Open, candle.High, candle.Low, candle.Close} {
+		largest = max(largest, math.Abs(price-previousClose)/previousClose)
+	}
+	return largest
+}
+
+func (s *Store) checkOutlier(config *Config, candle *data.Candle) string {
+	from := uint64(0)
+	if window := uint64(OutlierWindow.Milliseconds()); candle.ClosingTimestamp > window {
+		from = candle.ClosingTimestamp - window
+	}
+	history := s.candles.Range(config.MarketId, 60, from, candle.ClosingTimestamp-1)
+	if len(history) == 0 {
+		return ""
+	}
+	previous := history[len(history)-1]
+	move := jump(previous.Close, candle)
+	if config.MaxJumpPercent > 0 && move*100 > config.MaxJumpPercent {
+		return fmt.Sprintf("moved %.3f%% from previous close %f, limit is %.3f%%", move*100, previous.Close, config.MaxJumpPercent)
+	}
+	if len(history) <= OutlierMinHistory {
+		return ""
+	}
+	moves := make([]float64, 0, len(history)-1)
+	for i := 1; i < len(history); i++ {
+		moves = append(moves, jump(history[i-1].Close, history[i]))
+	}
+	mean := 0.0
+	for _, m := range moves {
+		mean += m
+	}
+	mean /= float64(len(moves))
+	variance := 0.0
+	for _, m := range moves {
+		variance += (m - mean) * (m - mean)
+	}
+	stdDev := math.Sqrt(variance / float64(len(moves)))
+	limit := config.MaxJumpStdDevs
+	if limit <= 0 {
+		limit = DefaultMaxJumpStdDevs
+	}
+	if stdDev > 0 && (move-mean)/stdDev > limit {
+		return fmt.Sprintf("moved %.3f%% from previous close %f, %.1f standard deviations above recent moves", move*100, previous.Close, (move-mean)/stdDev)
+	}
+	return ""
+}
+
+// accept runs the outlier checks on a candle that is about to be saved.
+// Flagged candles are quarantined instead of saved, unless they continue a
+// run of quarantined minutes, in which case the price level is taken to
+// have genuinely moved and the whole run is saved.
+func (s *Store) accept(config *Config, source string, candle *data.Candle) {
+	existing := s.candles.Range(config.MarketId, candle.Interval, candle.ClosingTimestamp, candle.ClosingTimestamp)
+	if len(existing) > 0 && existing[0].Equal(candle) {
+		return
+	}
+	reason := ""
+	if candle.Interval == 60 {
+		reason = s.checkOutlier(config, candle)
+	}
+	if len(reason) == 0 {
+		s.SaveCandle(candle)
+		s.DiscardQuarantined(config.MarketId, candle.ClosingTimestamp)
+		return
+	}
+	if run := s.takeQuarantinedRun(config.MarketId, candle.ClosingTimestamp); len(run) > 0 {
+		log.Warnf("accepting price level shift in %s at %d after %d quarantined minutes", config.MarketId, candle.ClosingTimestamp, len(run))
+		for _, quarantined := range run {
+			s.SaveCandle(quarantined.Candle)
+		}
+		s.SaveCandle(candle)
+		return
+	}
+	s.quarantine(config.MarketId, source, candle, reason)
+}
+
+func (s *Store) quarantine(marketId string, source string, candle *data.Candle, reason string) {
+	s.quarantined.lock.Lock()
+	defer s.quarantined.lock.Unlock()
+	candles := s.quarantined.candles[marketId]
+	if candles == nil {
+		candles = map[uint64]*QuarantinedCandle{}
+		s.quarantined.candles[marketId] = candles
+	}
+	existing := candles[candle.ClosingTimestamp]
+	if existing != nil && existing.Candle.Equal(candle) {
+		return
+	}
+	log.Warnf("quarantined %s candle at %d from %s: %s", marketId, candle.ClosingTimestamp, source, reason)
+	candles[candle.ClosingTimestamp] = &QuarantinedCandle{
+		Candle:        candle,
+		Source:        source,
+		Reason:        reason,
+		QuarantinedAt: s.clock.Now().UnixMilli(),
+	}
+	for len(candles) > QuarantineHistory {
+		oldest := uint64(math.MaxUint64)
+		for ts := range candles {
+			oldest = min(oldest, ts)
+		}
+		delete(candles, oldest)
+	}
+}
+
+func (s *Store) takeQuarantinedRun(marketId string, closingTimestamp uint64) []*QuarantinedCandle {
+	s.quarantined.lock.Lock()
+	defer s.quarantined.lock.Unlock()
+	candles := s.quarantined.candles[marketId]
+	run := make([]*QuarantinedCandle, 0, OutlierConfirmations)
+	for i := uint64(1); i <= OutlierConfirmations; i++ {
+		quarantined, ok := candles[closingTimestamp-i*60000]
+		if !ok {
+			return nil
+		}
+		run = append(run, quarantined)
+	}
+	for _, quarantined := range run {
+		delete(candles, quarantined.Candle.ClosingTimestamp)
+	}
+	sort.Slice(run, func(i, j int) bool {
+		return run[i].Candle.ClosingTimestamp < run[j].Candle.ClosingTimestamp
+	})
+	return run
+}
+
+func (s *Store) GetQuarantined(marketId string) []*QuarantinedCandle {
+	s.quarantined.lock.Lock()
+	defer s.quarantined.lock.Unlock()
+	result := make([]*QuarantinedCandle, 0, len(s.quarantined.candles[marketId]))
+	for _, quarantined := range s.quarantined.candles[marketId] {
+		copied := *quarantined
+		candle := *quarantined.Candle
+		copied.Candle = &candle
+		result = append(result, &copied)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Candle.ClosingTimestamp > result[j].Candle.ClosingTimestamp
+	})
+	return result
+}
+
+func (s *Store) ReleaseQuarantined(marketId string, closingTimestamp uint64) bool {
+	s.quarantined.lock.Lock()
+	quarantined, ok := s.quarantined.candles[marketId][closingTimestamp]
+	delete(s.quarantined.candles[marketId], closingTimestamp)
+	s.quarantined.lock.Unlock()
+	if !ok {
+		return false
+	}
+	log.Infof("released quarantined %s candle at %d", marketId, closingTimestamp)
+	s.SaveCandle(quarantined.Candle)
+	return true
+}
+
+func (s *Store) DiscardQuarantined(marketId string, closingTimestamp uint64) bool {
+	s.quarantined.lock.Lock()
+	defer s.quarantined.lock.Unlock()
+	_, ok := s.quarantined.candles[marketId][closingTimestamp]
+	delete(s.quarantined.candles[marketId], closingTimestamp)
+	return ok
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"candles-api/data"
+	"candles-api/provider"
+	"math"
+	"testing"
+)
+
+func TestValidateCandle(t *testing.T) {
+	tests := []struct {
+		name   string
+		candle *data.Candle
+		valid  bool
+	}{
+		{"valid", data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 10, 11, 12, 9, 1, 10), true},
+		{"zero price", data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 0, 11, 12, 9, 1, 10), false},
+		{"nan price", data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, math.NaN(), 11, 12, 9, 1, 10), false},
+		{"high below low", data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 10, 10, 9, 12, 1, 10), false},
+		{"close above high", data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 10, 13, 12, 9, 1, 10), false},
+		{"negative volume", data.NewCandle("BTCUSDT", "btc", 60, 120000, 60000, 10, 11, 12, 9, -1, 10), false},
+		{"bad bounds", data.NewCandle("BTCUSDT", "btc", 60, 120000, 0, 10, 11, 12, 9, 1, 10), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateCandle(test.candle)
+			if test.valid && err != nil {
+				t.Fatalf("expected valid candle, got %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("expected invalid candle")
+			}
+		})
+	}
+}
+
+func TestStore_QuarantinesOutliers(t *testing.T) {
+	btc := &Config{MarketId: "btc", PriceSource: "bybit", Symbol: "BTCUSDT", MaxJumpPercent: 5}
+	s := NewStore(testIntervals, []*Config{btc}, provider.NewRegistry(), nil, nil)
+	source := btc.AllSources()[0]
+	ts := uint64(60000)
+	for i := 0; i < 60; i++ {
+		price := 100 + float64(i%2)*0.1
+		ts += 60000
+		s.ingest(btc, source, []*data.Candle{candleAt(ts, price)})
+	}
+	if len(s.GetQuarantined("btc")) != 0 {
+		t.Fatalf("expected no quarantined candles, got %+v", s.GetQuarantined("btc"))
+	}
+
+	ts += 60000
+	s.ingest(btc, source, []*data.Candle{candleAt(ts, 101)})
+	quarantined := s.GetQuarantined("btc")
+	if len(quarantined) != 1 || quarantined[0].Candle.ClosingTimestamp != ts || quarantined[0].Source != source.Key() {
+		t.Fatalf("expected a standard deviation outlier, got %+v", quarantined)
+	}
+	if latest := s.GetLatestCandles("btc", 60, 1); latest[0].ClosingTimestamp == ts {
+		t.Fatal("expected the outlier not to be saved")
+	}
+
+	if !s.ReleaseQuarantined("btc", ts) || len(s.GetQuarantined("btc")) != 0 {
+		t.Fatal("expected the outlier to be released")
+	}
+	if latest := s.GetLatestCandles("btc", 60, 1); latest[0].ClosingTimestamp != ts {
+		t.Fatal("expected the released candle to be saved")
+	}
+
+	for i := 0; i < OutlierConfirmations; i++ {
+		ts += 60000
+		s.ingest(btc, source, []*data.Candle{candleAt(ts, 120)})
+	}
+	if len(s.GetQuarantined("btc")) != OutlierConfirmations {
+		t.Fatalf("expected percentage outliers, got %+v", s.GetQuarantined("btc"))
+	}
+	ts += 60000
+	s.ingest(btc, source, []*data.Candle{candleAt(ts, 120)})
+	if len(s.GetQuarantined("btc")) != 0 || len(s.GetCandles("btc", 60, ts-60000*OutlierConfirmations, ts)) != OutlierConfirmations+1 {
+		t.Fatal("expected a sustained move to be accepted")
+	}
+
+	s.ingest(btc, source, []*data.Candle{data.NewCandle("BTCUSDT", "btc", 60, ts+60000, ts, 0, 120, 120, 120, 0, 0)})
+	if quarantined := s.GetQuarantined("btc"); len(quarantined) != 1 || !s.DiscardQuarantined("btc", ts+60000) {
+		t.Fatalf("expected an invalid candle to be quarantined, got %+v", quarantined)
+	}
+}
@@ -0,0 +1,72 @@
+package testutil
+
+import (
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+//go:embed payloads
+var payloads embed.FS
+
+// Response is a canned reply of a fake upstream.
+type Response struct {
+	Status int
+	Body   string
+}
+
+// Server is a fake upstream that answers requests with its responses in
+// order, repeating the last one once they run out, and records the urls it
+// was asked for.
+type Server struct {
+	*httptest.Server
+	responses []Response
+	requests  []*url.URL
+	lock      sync.Mutex
+}
+
+func NewServer(t testing.TB, responses ...Response) *Server {
+	s := &Server{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	s.requests = append(s.requests, r.URL)
+	response := Response{Status: http.StatusNotFound}
+	if len(s.responses) > 0 {
+		response = s.responses[min(len(s.requests), len(s.responses))-1]
+	}
+	s.lock.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.Status)
+	_, _ = w.Write([]byte(response.Body))
+}
+
+func (s *Server) Requests() []*url.URL {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*url.URL{}, s.requests...)
+}
+
+// Payload returns a recorded upstream body, e.g. "polygon/aggregates.json".
+func Payload(t testing.TB, name string) string {
+	raw, err := payloads.ReadFile("payloads/" + name)
+	if err != nil {
+		t.Fatalf("cannot read payload %s %v", name, err)
+	}
+	return string(raw)
+}
+
+func OK(t testing.TB, name string) Response {
+	return Response{Status: http.StatusOK, Body: Payload(t, name)}
+}
+
+func Status(t testing.TB, status int, name string) Response {
+	return Response{Status: status, Body: Payload(t, name)}
+}
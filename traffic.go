@@ -0,0 +1,126 @@
+package main
+
+import (
+	"candles-api/clock"
+	"candles-api/journal"
+	"candles-api/provider"
+	"candles-api/replay"
+	"errors"
+	"flag"
+	"github.com/charmbracelet/log"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+type traffic struct {
+	recordDir *string
+	replayDir *string
+	recorders []io.Closer
+	clock     clock.Clock
+}
+
+func trafficFlags(flags *flag.FlagSet) *traffic {
+	return &traffic{
+		recordDir: flags.String("record", "", "directory to record every raw provider response and streamed candle to"),
+		replayDir: flags.String("replay", "", "directory of recorded provider responses to serve on their original timeline instead of calling the providers, requires an empty --data-dir"),
+		clock:     clock.System(),
+	}
+}
+
+// start sets up the clock the store runs on, which follows the recording
+// when replaying. It must be called once the flags are parsed. A replay
+// writes candles from the past, so it refuses to run against the default
+// data dir or any dir that already holds a journal.
+func (t *traffic) start(flags *flag.FlagSet, dataDir string) {
+	if len(*t.recordDir) > 0 && len(*t.replayDir) > 0 {
+		log.Fatal("--record and --replay cannot be combined")
+	}
+	if !t.replaying() {
+		return
+	}
+	explicit := false
+	flags.Visit(func(f *flag.Flag) {
+		explicit = explicit || f.Name == "data-dir"
+	})
+	if !explicit {
+		log.Fatal("--replay requires an explicit --data-dir for the replayed candles")
+	}
+	exists, err := journal.Exists(dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if exists {
+		log.Fatalf("--data-dir %s already holds a journal, --replay requires an empty one", dataDir)
+	}
+	started, err := replay.Start(*t.replayDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("replaying provider traffic from %s starting at %s", *t.replayDir, started.Format(time.DateTime))
+	t.clock = clock.StartingAt(started)
+}
+
+func (t *traffic) enabled() bool {
+	return len(*t.recordDir) > 0 || t.replaying()
+}
+
+func (t *traffic) replaying() bool {
+	return len(*t.replayDir) > 0
+}
+
+// transport returns the round tripper that records or replays the requests
+// of a provider.
+func (t *traffic) transport(name string) http.RoundTripper {
+	if t.replaying() {
+		replayer, err := replay.Load(*t.replayDir, name, t.clock)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return replayer
+	}
+	if len(*t.recordDir) > 0 {
+		recorder, err := replay.NewRecorder(*t.recordDir, name, http.DefaultTransport, t.clock)
+		if err != nil {
+			log.Fatal(err)
+		}
+		t.recorders = append(t.recorders, recorder)
+		return recorder
+	}
+	return http.DefaultTransport
+}
+
+// stream records or replays what a streaming provider pushes, which bypasses
+// its transport. A replay without a recorded stream polls the provider
+// instead.
+func (t *traffic) stream(p provider.Provider) provider.Provider {
+	if t.replaying() {
+		replayer, err := replay.LoadStream(*t.replayDir, p, t.clock)
+		if errors.Is(err, os.ErrNotExist) {
+			log.Warnf("no %s stream recorded in %s, polling it instead", p.Name(), *t.replayDir)
+			return provider.Polled(p)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return replayer
+	}
+	if len(*t.recordDir) > 0 {
+		recorder, err := replay.NewStreamRecorder(*t.recordDir, p, t.clock)
+		if err != nil {
+			log.Fatal(err)
+		}
+		t.recorders = append(t.recorders, recorder)
+		return recorder
+	}
+	return p
+}
+
+func (t *traffic) close() {
+	for _, recorder := range t.recorders {
+		if err := recorder.Close(); err != nil {
+			log.Errorf("cannot close recording %v", err)
+		}
+	}
+}
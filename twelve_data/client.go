@@ -2,14 +2,25 @@ package twelve_data
 
 import (
 	"candles-api/data"
+	"candles-api/provider"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/charmbracelet/log"
 	"github.com/go-resty/resty/v2"
+	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	Name          = "twelve-data"
+	noDataMessage = "No data is available"
+)
+
 var timeZones = map[string]string{
 	"COMMODITY": "Australia/Sydney",
 	"XLON":      "Europe/London",
@@ -18,31 +29,96 @@ var timeZones = map[string]string{
 	"XJPX":      "Asia/Tokyo",
 }
 
+var intervals = map[uint64]string{
+	60:    "1min",
+	300:   "5min",
+	900:   "15min",
+	1800:  "30min",
+	3600:  "1h",
+	14400: "4h",
+	86400: "1day",
+}
+
 type Client struct {
-	host   string
-	apiKey string
+	baseUrl string
+	apiKey  string
+	client  *resty.Client
 }
 
 func NewClient(host string, apiKey string) *Client {
-	return &Client{host: host, apiKey: apiKey}
+	return &Client{baseUrl: "https://" + host, apiKey: apiKey, client: resty.New()}
+}
+
+func (c *Client) WithBaseUrl(baseUrl string) *Client {
+	c.baseUrl = baseUrl
+	return c
+}
+
+func (c *Client) WithHttpClient(httpClient *http.Client) *Client {
+	c.client = resty.NewWithClient(httpClient)
+	return c
 }
 
-func (c *Client) GetLatestCandles(symbol string, micCode string) []*data.Candle {
-	client := resty.New()
+func (c *Client) Name() string {
+	return Name
+}
+
+func (c *Client) Intervals() []uint64 {
+	return []uint64{60, 300, 900, 1800, 3600, 14400, 86400}
+}
+
+func (c *Client) PollInterval() time.Duration {
+	return time.Second * 15
+}
+
+func (c *Client) GetLatestCandles(ctx context.Context, symbol string, micCode string) ([]*data.Candle, error) {
 	url := fmt.Sprintf(
-		"https://%s/time_series?symbol=%s&interval=1min&apikey=%s&mic_code=%s&outputsize=5000",
-		c.host, symbol, c.apiKey, micCode,
+		"%s/time_series?symbol=%s&interval=1min&apikey=%s&mic_code=%s&outputsize=5000",
+		c.baseUrl, symbol, c.apiKey, micCode,
 	)
-	resp, err := client.R().Get(url)
+	return c.getCandles(ctx, url, symbol, location(micCode), 60)
+}
+
+func (c *Client) GetCandles(ctx context.Context, symbol string, micCode string, interval uint64, from time.Time, to time.Time) ([]*data.Candle, error) {
+	intervalStr, ok := intervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("twelve data does not support interval %d", interval)
+	}
+	// bars above 1m are requested in UTC so they line up with the buckets
+	// aggregated from 1m candles instead of the exchange's local day
+	tz := location(micCode)
+	timeZone := ""
+	if interval > 60 {
+		tz = time.UTC
+		timeZone = "&timezone=UTC"
+	}
+	return provider.Page(ctx, from, to, interval, 5000, time.Second*8, func(from time.Time, to time.Time) ([]*data.Candle, error) {
+		uri := fmt.Sprintf(
+			"%s/time_series?symbol=%s&interval=%s&apikey=%s&mic_code=%s&outputsize=5000&start_date=%s&end_date=%s%s",
+			c.baseUrl, symbol, intervalStr, c.apiKey, micCode,
+			url.QueryEscape(from.In(tz).Format(time.DateTime)), url.QueryEscape(to.In(tz).Format(time.DateTime)), timeZone,
+		)
+		return c.getCandles(ctx, uri, symbol, tz, interval)
+	})
+}
+
+func (c *Client) getCandles(ctx context.Context, url string, symbol string, tz *time.Location, interval uint64) ([]*data.Candle, error) {
+	resp, err := c.client.R().SetContext(ctx).Get(url)
 	candles := make([]*data.Candle, 0)
 	if err != nil {
-		log.Errorf("cannot get candles from twelve data %v", err)
-		return candles
+		return candles, fmt.Errorf("cannot get candles from twelve data %v", err)
 	}
 	if resp.StatusCode() != 200 {
-		log.Errorf("cannot get candles from twelve data %s", string(resp.Body()))
-		return candles
+		return candles, fmt.Errorf("cannot get candles from twelve data %s %s", resp.Status(), string(resp.Body()))
 	}
+	return parseTimeSeries(resp.Body(), symbol, tz, interval)
+}
+
+// parseTimeSeries reads bar datetimes in tz. Bars that do not start on a UTC
+// interval boundary, e.g. a session-anchored 4h bar, are skipped, they would
+// overlap the candles aggregated from 1m.
+func parseTimeSeries(body []byte, symbol string, tz *time.Location, interval uint64) ([]*data.Candle, error) {
+	candles := make([]*data.Candle, 0)
 	res := struct {
 		Meta struct {
 			Symbol string `json:"symbol"`
@@ -54,33 +130,52 @@ func (c *Client) GetLatestCandles(symbol string, micCode string) []*data.Candle
 			Low      string `json:"low"`
 			Close    string `json:"close"`
 		} `json:"values"`
+		Status  string `json:"status"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
 	}{}
-	err = json.Unmarshal(resp.Body(), &res)
+	err := json.Unmarshal(body, &res)
 	if err != nil {
-		log.Errorf("cannot get candles from twelve data %v", err)
-		return candles
+		return candles, fmt.Errorf("cannot get candles from twelve data %v", err)
 	}
+	// errors, rate limits included, are reported in the body of a 200 response
+	if res.Status == "error" {
+		if strings.HasPrefix(res.Message, noDataMessage) {
+			return candles, nil
+		}
+		return candles, fmt.Errorf("cannot get candles from twelve data %d %s", res.Code, res.Message)
+	}
+	misaligned := 0
 	for _, item := range res.Values {
-		tz, _ := time.LoadLocation("UTC")
-		if len(timeZones[micCode]) > 0 {
-			tz, _ = time.LoadLocation(timeZones[micCode])
+		layout := time.DateTime
+		if len(item.DateTime) == len(time.DateOnly) {
+			layout = time.DateOnly
 		}
-		closingTimestamp, err := time.ParseInLocation(time.DateTime, item.DateTime, tz)
+		openingTimestamp, err := time.ParseInLocation(layout, item.DateTime, tz)
 		if err != nil {
-			log.Errorf("cannot get closing timestamp from twelve data %v", err)
+			log.Errorf("cannot get opening timestamp from twelve data %v", err)
+			continue
+		}
+		if uint64(openingTimestamp.UnixMilli())%(interval*1000) != 0 {
+			misaligned++
+			continue
+		}
+		openPrice, err1 := strconv.ParseFloat(item.Open, 64)
+		highPrice, err2 := strconv.ParseFloat(item.High, 64)
+		lowPrice, err3 := strconv.ParseFloat(item.Low, 64)
+		closePrice, err4 := strconv.ParseFloat(item.Close, 64)
+		if err := errors.Join(err1, err2, err3, err4); err != nil {
+			log.Errorf("cannot parse prices from twelve data %v", err)
+			continue
 		}
-		openPrice, _ := strconv.ParseFloat(item.Open, 64)
-		highPrice, _ := strconv.ParseFloat(item.High, 64)
-		lowPrice, _ := strconv.ParseFloat(item.Low, 64)
-		closePrice, _ := strconv.ParseFloat(item.Close, 64)
 		volume := 0.0
 		turnover := 0.0
 		candles = append(candles, data.NewCandle(
 			symbol,
 			"",
-			60,
-			uint64(closingTimestamp.UnixMilli()),
-			uint64(closingTimestamp.UnixMilli()-60000),
+			interval,
+			uint64(openingTimestamp.UnixMilli())+interval*1000,
+			uint64(openingTimestamp.UnixMilli()),
 			openPrice,
 			closePrice,
 			highPrice,
@@ -89,5 +184,16 @@ func (c *Client) GetLatestCandles(symbol string, micCode string) []*data.Candle
 			turnover,
 		))
 	}
-	return candles
+	if misaligned > 0 {
+		log.Warnf("skipped %d %s %ds candles from twelve data not aligned to UTC", misaligned, symbol, interval)
+	}
+	return candles, nil
+}
+
+func location(micCode string) *time.Location {
+	tz, _ := time.LoadLocation("UTC")
+	if len(timeZones[micCode]) > 0 {
+		tz, _ = time.LoadLocation(timeZones[micCode])
+	}
+	return tz
 }
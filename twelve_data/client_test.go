@@ -1,13 +1,169 @@
 package twelve_data
 
 import (
-	"os"
+	"candles-api/data"
+	"candles-api/testutil"
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
-var ApiKey = os.Getenv("TWELVE_DATA_API_KEY")
+func TestClient_GetCandles(t *testing.T) {
+	sydney, _ := time.LoadLocation("Australia/Sydney")
+	from := time.Date(2024, 1, 2, 10, 0, 0, 0, sydney)
+	to := from.Add(time.Minute)
+	first := uint64(from.UnixMilli())
+	tests := []struct {
+		name      string
+		responses []testutil.Response
+		expected  []*data.Candle
+		err       string
+	}{
+		{
+			name:      "time series",
+			responses: []testutil.Response{testutil.OK(t, "twelve_data/time_series.json")},
+			expected: []*data.Candle{
+				data.NewCandle("XAU/USD", "", 60, first+120000, first+60000, 2063.10, 2063.20, 2063.50, 2062.90, 0, 0),
+				data.NewCandle("XAU/USD", "", 60, first+60000, first, 2062.80, 2063.10, 2063.20, 2062.70, 0, 0),
+			},
+		},
+		{
+			name:      "no data",
+			responses: []testutil.Response{testutil.OK(t, "twelve_data/empty.json")},
+			expected:  []*data.Candle{},
+		},
+		{
+			name:      "invalid api key",
+			responses: []testutil.Response{testutil.OK(t, "twelve_data/error.json")},
+			err:       "401 **apikey** parameter is incorrect",
+		},
+		{
+			name:      "out of credits",
+			responses: []testutil.Response{testutil.OK(t, "twelve_data/rate_limit.json")},
+			err:       "429 You have run out of API credits",
+		},
+		{
+			name:      "http 429",
+			responses: []testutil.Response{{Status: http.StatusTooManyRequests}},
+			err:       "429 Too Many Requests",
+		},
+		{
+			name:      "malformed json",
+			responses: []testutil.Response{testutil.OK(t, "malformed.json")},
+			err:       "unexpected end of JSON input",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := testutil.NewServer(t, test.responses...)
+			client := NewClient("", "key").WithBaseUrl(server.URL).WithHttpClient(server.Client())
+			candles, err := client.GetCandles(context.Background(), "XAU/USD", "COMMODITY", 60, from, to)
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(candles, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, candles)
+			}
+			requests := server.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("expected 1 request, got %v", requests)
+			}
+			query := requests[0].Query()
+			if requests[0].Path != "/time_series" || query.Get("symbol") != "XAU/USD" ||
+				query.Get("mic_code") != "COMMODITY" || query.Get("start_date") != "2024-01-02 10:00:00" ||
+				query.Get("end_date") != "2024-01-02 10:01:00" || query.Get("apikey") != "key" {
+				t.Fatalf("unexpected requests %v", requests)
+			}
+		})
+	}
+}
+
+func TestParseTimeSeries(t *testing.T) {
+	candles, err := parseTimeSeries([]byte(testutil.Payload(t, "twelve_data/time_series.json")), "XAU/USD", location("COMMODITY"), 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles, got %d", len(candles))
+	}
+	sydney, _ := time.LoadLocation("Australia/Sydney")
+	start := time.Date(2024, 1, 2, 10, 1, 0, 0, sydney).UnixMilli()
+	latest := candles[0]
+	if latest.OpeningTimestamp != uint64(start) || latest.ClosingTimestamp != uint64(start)+60000 {
+		t.Fatalf("expected datetime as the opening timestamp, got %+v", latest)
+	}
+	if latest.Open != 2063.10 || latest.High != 2063.50 || latest.Low != 2062.90 || latest.Close != 2063.20 {
+		t.Fatalf("unexpected prices %+v", latest)
+	}
+}
+
+func TestParseTimeSeries_Daily(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		interval uint64
+		expected []uint64
+	}{
+		{
+			name:     "sydney daily",
+			payload:  `{"meta":{"symbol":"XAU/USD"},"values":[{"datetime":"2024-01-02","open":"2063.1","high":"2070.5","low":"2055.2","close":"2066.4"}],"status":"ok"}`,
+			interval: 86400,
+			expected: []uint64{uint64(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC).UnixMilli())},
+		},
+		{
+			name:     "summer london daily",
+			payload:  `{"meta":{"symbol":"FTSE"},"values":[{"datetime":"2024-07-02","open":"8121.2","high":"8140.1","low":"8101.4","close":"8121.5"}],"status":"ok"}`,
+			interval: 86400,
+			expected: []uint64{uint64(time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC).UnixMilli())},
+		},
+		{
+			name: "session anchored 4h",
+			payload: `{"meta":{"symbol":"XAU/USD"},"values":[{"datetime":"2024-07-02 16:00:00","open":"1","high":"1","low":"1","close":"1"},` +
+				`{"datetime":"2024-07-02 14:00:00","open":"1","high":"1","low":"1","close":"1"}],"status":"ok"}`,
+			interval: 14400,
+			expected: []uint64{uint64(time.Date(2024, 7, 2, 16, 0, 0, 0, time.UTC).UnixMilli())},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			candles, err := parseTimeSeries([]byte(test.payload), "XAU/USD", time.UTC, test.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(candles) != len(test.expected) {
+				t.Fatalf("expected %d candles, got %+v", len(test.expected), candles)
+			}
+			for i, start := range test.expected {
+				if candles[i].OpeningTimestamp != start || candles[i].ClosingTimestamp != start+test.interval*1000 {
+					t.Fatalf("unexpected bounds %+v", candles[i])
+				}
+			}
+		})
+	}
+}
 
-func TestClient_GetLatestCandles(t *testing.T) {
-	client := NewClient("api.twelvedata.com", ApiKey)
-	client.GetLatestCandles("WTI/USD", "COMMODITY")
+func TestClient_GetCandlesRequestsUtcAbove1m(t *testing.T) {
+	server := testutil.NewServer(t, testutil.OK(t, "twelve_data/empty.json"))
+	client := NewClient("", "key").WithBaseUrl(server.URL).WithHttpClient(server.Client())
+	from := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := client.GetCandles(context.Background(), "FTSE", "XLON", 86400, from, from.Add(time.Hour*48)); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %v", requests)
+	}
+	query := requests[0].Query()
+	if query.Get("interval") != "1day" || query.Get("timezone") != "UTC" || query.Get("start_date") != "2024-07-01 00:00:00" {
+		t.Fatalf("expected daily bars requested in UTC, got %v", requests[0])
+	}
 }